@@ -0,0 +1,448 @@
+package dsp
+
+import (
+	"fmt"
+	"math/cmplx"
+	"sync/atomic"
+	"time"
+)
+
+// AECConfig configures the acoustic echo canceller
+type AECConfig struct {
+	// TailLength is how long an echo the adaptive filter can model, after
+	// the bulk delay found by delay estimation
+	TailLength time.Duration
+	// MaxDelay is the longest speaker-to-microphone delay searched for,
+	// including output and input buffering
+	MaxDelay time.Duration
+	// StepSize is the normalised adaptation rate (0-1); larger adapts faster
+	// but leaves more residual echo
+	StepSize float64
+	// DoubleTalkThreshold is the Geigel detector ratio: near-end speech is
+	// assumed when the microphone peaks above this fraction of the reference
+	DoubleTalkThreshold float32
+}
+
+// DefaultAECConfig returns settings suited to laptop speakers in a small room
+func DefaultAECConfig() AECConfig {
+	return AECConfig{
+		TailLength:          80 * time.Millisecond,
+		MaxDelay:            500 * time.Millisecond,
+		StepSize:            0.5,
+		DoubleTalkThreshold: 0.5,
+	}
+}
+
+const (
+	// aecDelayWindow is how much history delay estimation correlates
+	aecDelayWindow = 500 * time.Millisecond
+	// aecDelayInterval is how many blocks pass between delay estimates
+	aecDelayInterval = 25
+	// aecDelayMargin starts the adaptive filter this long before the
+	// estimated delay so the start of the echo isn't cut off
+	aecDelayMargin = 5 * time.Millisecond
+	// aecDoubleTalkHold keeps adaptation frozen for this many blocks after
+	// near-end speech was last detected
+	aecDoubleTalkHold = 5
+	// aecSilence is the reference peak below which there is nothing to adapt to
+	aecSilence = 1e-3
+	// aecERLESmoothing averages echo and residual energy over about 200ms
+	aecERLESmoothing = 0.95
+	// aecConverged is the echo reduction (as a power ratio, 6 dB) above which
+	// the residual double-talk detector is trusted
+	aecConverged = 4
+	// aecResidualMargin is how far (6 dB) the residual may rise above what
+	// the echo reduction predicts before near-end speech is assumed
+	aecResidualMargin = 4
+	// aecPathChangeBlocks is how long (1.5s) the residual can stay high
+	// without the Geigel detector agreeing before the echo path is assumed to
+	// have changed
+	aecPathChangeBlocks = 150
+)
+
+// AEC is an acoustic echo canceller Processor. It removes the far-end
+// reference (what the speakers play) from the microphone signal with a
+// partitioned-block frequency-domain adaptive filter. The bulk delay between
+// the two is estimated automatically, and adaptation pauses while the
+// near-end user talks so their voice doesn't disturb the filter.
+//
+// Call SetReference with the matching far-end block before every Process
+// call; blocks without a reference are treated as far-end silence.
+type AEC struct {
+	channels    int
+	block       int // samples per channel per frame
+	partitions  int
+	stepSize    float64
+	dtThreshold float64
+	margin      int
+
+	enabled        atomic.Bool
+	estimatedDelay atomic.Int64
+	doubleTalk     atomic.Bool
+
+	// Far-end history, long enough for the largest delay plus the tail
+	far        []float32
+	farWritten int // total samples appended
+	reference  bool
+
+	fft        *FFT
+	spectra    [][]complex128   // recent aligned far-end spectra, newest at spectraPos
+	filters    [][][]complex128 // [channel][partition] filter spectra
+	power      []float64        // far-end power per bin summed over partitions
+	spectrum   []complex128     // scratch
+	errSpecs   [][]complex128   // last error per channel, for adaptation
+	gradient   []complex128     // scratch
+	spectraPos int
+
+	delay     int // alignment applied to the far end, in samples
+	estimator *delayEstimator
+	blocks    int
+
+	// Double-talk detection
+	dtHold         int
+	micAverage     float64 // smoothed microphone energy while adapting
+	errAverage     float64 // smoothed residual energy while adapting
+	residualBlocks int
+}
+
+var _ Processor = (*AEC)(nil)
+
+// NewAEC creates an enabled echo canceller for interleaved audio with the
+// given sample rate and channel count, processing 10ms frames
+func NewAEC(sampleRate, channels int, cfg AECConfig) *AEC {
+	block := sampleRate / 100
+	size := 1
+	for size < 2*block {
+		size *= 2
+	}
+
+	tail := int(cfg.TailLength.Seconds() * float64(sampleRate))
+	maxDelay := int(cfg.MaxDelay.Seconds() * float64(sampleRate))
+	a := &AEC{
+		block:       block,
+		partitions:  max(1, (tail+block-1)/block),
+		stepSize:    cfg.StepSize,
+		dtThreshold: float64(cfg.DoubleTalkThreshold),
+		margin:      int(aecDelayMargin.Seconds() * float64(sampleRate)),
+		fft:         NewFFT(size),
+		power:       make([]float64, size),
+		spectrum:    make([]complex128, size),
+		gradient:    make([]complex128, size),
+		estimator:   newDelayEstimator(int(aecDelayWindow.Seconds()*float64(sampleRate)), maxDelay),
+	}
+	a.far = make([]float32, maxDelay+a.partitions*block+size)
+	a.spectra = make([][]complex128, a.partitions)
+	for i := range a.spectra {
+		a.spectra[i] = make([]complex128, size)
+	}
+	a.enabled.Store(true)
+	a.SetChannels(channels)
+	return a
+}
+
+// SetReference provides the far-end audio, mono, for the next frame
+func (a *AEC) SetReference(reference []float32) error {
+	if len(reference) != a.block {
+		return fmt.Errorf("echo reference has %d samples, expected %d", len(reference), a.block)
+	}
+	a.appendFar(reference)
+	a.reference = true
+	return nil
+}
+
+// appendFar adds samples to the far-end history
+func (a *AEC) appendFar(samples []float32) {
+	for _, sample := range samples {
+		a.far[a.farWritten%len(a.far)] = sample
+		a.farWritten++
+	}
+}
+
+// farAt returns the far-end sample at absolute index i, or 0 if it's
+// outside the history
+func (a *AEC) farAt(i int) float32 {
+	if i < 0 || i >= a.farWritten || i < a.farWritten-len(a.far) {
+		return 0
+	}
+	return a.far[i%len(a.far)]
+}
+
+// Process removes echo from one interleaved frame in place
+func (a *AEC) Process(frame []float32) error {
+	if len(frame) != a.block*a.channels {
+		return fmt.Errorf("echo canceller expects %d samples, got %d", a.block*a.channels, len(frame))
+	}
+	if !a.reference {
+		// Keep the history in step with the microphone
+		for i := 0; i < a.block; i++ {
+			a.far[a.farWritten%len(a.far)] = 0
+			a.farWritten++
+		}
+	}
+	a.reference = false
+	if !a.enabled.Load() {
+		return nil
+	}
+
+	// Time index just past the newest far-end sample, which lines up with
+	// the end of this microphone block
+	end := a.farWritten
+	a.updateDelay(frame, end)
+
+	// Aligned far-end spectrum for the newest partition
+	size := a.fft.Size()
+	a.spectraPos = (a.spectraPos + 1) % a.partitions
+	x := a.spectra[a.spectraPos]
+	for i := range x {
+		x[i] = complex(float64(a.farAt(end-a.delay-size+i)), 0)
+	}
+	a.fft.Forward(x)
+
+	for i := range a.power {
+		a.power[i] = 0
+	}
+	for _, spec := range a.spectra {
+		for i, bin := range spec {
+			a.power[i] += real(bin)*real(bin) + imag(bin)*imag(bin)
+		}
+	}
+
+	var micPeak float32
+	var micEnergy, errEnergy float64
+	for _, sample := range frame {
+		micPeak = max(micPeak, abs32(sample))
+		micEnergy += float64(sample) * float64(sample)
+	}
+	for ch := 0; ch < a.channels; ch++ {
+		errEnergy += a.cancel(frame, ch)
+	}
+
+	farPeak := a.farPeak(end)
+	if farPeak <= aecSilence {
+		// Nothing is playing, so there is nothing to learn from
+		a.dtHold = 0
+		a.doubleTalk.Store(false)
+		return nil
+	}
+	if a.detectDoubleTalk(float64(micPeak), farPeak, micEnergy, errEnergy) {
+		return nil
+	}
+	for ch := 0; ch < a.channels; ch++ {
+		a.adapt(ch)
+	}
+	return nil
+}
+
+// cancel subtracts the echo estimate from one channel, keeps the error for
+// adaptation and returns its energy
+func (a *AEC) cancel(frame []float32, ch int) float64 {
+	size := a.fft.Size()
+	filter := a.filters[ch]
+
+	// Echo estimate: sum of each partition's filter times the far-end
+	// spectrum it was delayed by
+	clear(a.spectrum)
+	for k := range filter {
+		x := a.spectra[(a.spectraPos-k+a.partitions)%a.partitions]
+		for i, w := range filter[k] {
+			a.spectrum[i] += w * x[i]
+		}
+	}
+	a.fft.Inverse(a.spectrum)
+
+	// Overlap-save: the last block of the circular convolution is valid
+	errSpec := a.errSpecs[ch]
+	clear(errSpec)
+	var energy float64
+	for n := 0; n < a.block; n++ {
+		i := n*a.channels + ch
+		e := frame[i] - float32(real(a.spectrum[size-a.block+n]))
+		frame[i] = e
+		errSpec[size-a.block+n] = complex(float64(e), 0)
+		energy += float64(e) * float64(e)
+	}
+	return energy
+}
+
+// adapt updates one channel's filter from its last error (NLMS normalised
+// per frequency bin)
+func (a *AEC) adapt(ch int) {
+	filter := a.filters[ch]
+	errSpec := a.errSpecs[ch]
+	a.fft.Forward(errSpec)
+
+	regularisation := float64(a.fft.Size()) * aecSilence * aecSilence
+	for k := range filter {
+		x := a.spectra[(a.spectraPos-k+a.partitions)%a.partitions]
+		for i := range a.gradient {
+			a.gradient[i] = cmplx.Conj(x[i]) * errSpec[i] *
+				complex(a.stepSize/(a.power[i]+regularisation), 0)
+		}
+
+		// Constrain the update to a causal block of taps so partitions
+		// don't overlap
+		a.fft.Inverse(a.gradient)
+		for i := range a.gradient {
+			if i < a.block {
+				a.gradient[i] = complex(real(a.gradient[i]), 0)
+			} else {
+				a.gradient[i] = 0
+			}
+		}
+		a.fft.Forward(a.gradient)
+		for i := range filter[k] {
+			filter[k][i] += a.gradient[i]
+		}
+	}
+}
+
+// farPeak returns the largest aligned far-end sample that can still be
+// echoing in this block
+func (a *AEC) farPeak(end int) float64 {
+	var peak float32
+	for i := end - a.delay - a.partitions*a.block - a.block; i < end-a.delay; i++ {
+		peak = max(peak, abs32(a.farAt(i)))
+	}
+	return float64(peak)
+}
+
+// detectDoubleTalk decides whether the near-end user is talking. A Geigel
+// detector catches speech louder than any echo could be: the echo is at
+// most the reference times the echo path gain. Once the filter has
+// converged, an error well above what the echo reduction so far predicts
+// catches quieter speech too.
+func (a *AEC) detectDoubleTalk(micPeak, farPeak, micEnergy, errEnergy float64) bool {
+	geigel := micPeak > a.dtThreshold*farPeak
+
+	residual := false
+	if a.micAverage > aecConverged*a.errAverage {
+		residual = errEnergy*a.micAverage > aecResidualMargin*micEnergy*a.errAverage
+	}
+	if residual && !geigel {
+		// Nobody talks over the far end for this long: the echo path
+		// changed, so start measuring convergence again
+		a.residualBlocks++
+		if a.residualBlocks > aecPathChangeBlocks {
+			a.micAverage, a.errAverage = 0, 0
+			a.residualBlocks = 0
+		}
+	} else {
+		a.residualBlocks = 0
+	}
+
+	if geigel || residual {
+		a.dtHold = aecDoubleTalkHold
+	} else if a.dtHold > 0 {
+		a.dtHold--
+	}
+	talking := a.dtHold > 0
+	a.doubleTalk.Store(talking)
+
+	if !talking {
+		a.micAverage = aecERLESmoothing*a.micAverage + (1-aecERLESmoothing)*micEnergy
+		a.errAverage = aecERLESmoothing*a.errAverage + (1-aecERLESmoothing)*errEnergy
+	}
+	return talking
+}
+
+// updateDelay feeds the delay estimator and realigns the far end when the
+// echo delay moves outside the filter's reach
+func (a *AEC) updateDelay(frame []float32, end int) {
+	start := end - a.block
+	for n := 0; n < a.block; n++ {
+		var mic float32
+		for ch := 0; ch < a.channels; ch++ {
+			mic += frame[n*a.channels+ch]
+		}
+		a.estimator.add(mic/float32(a.channels), a.farAt(start+n))
+	}
+
+	a.blocks++
+	if a.blocks%aecDelayInterval != 0 {
+		return
+	}
+	delay, ok := a.estimator.estimate()
+	if !ok {
+		return
+	}
+	aligned := max(0, delay-a.margin)
+	if abs(aligned-a.delay) <= a.margin/2 {
+		return
+	}
+
+	a.delay = aligned
+	a.estimatedDelay.Store(int64(delay))
+	a.resetFilters()
+}
+
+// resetFilters clears the adaptive filters and far-end spectra
+func (a *AEC) resetFilters() {
+	a.micAverage, a.errAverage = 0, 0
+	a.residualBlocks = 0
+	a.spectraPos = 0
+	for _, spec := range a.spectra {
+		clear(spec)
+	}
+	for _, filter := range a.filters {
+		for _, partition := range filter {
+			clear(partition)
+		}
+	}
+}
+
+// SetChannels changes the number of interleaved microphone channels and
+// resets the filters. Must not be called while Process is running.
+func (a *AEC) SetChannels(channels int) {
+	a.channels = max(1, channels)
+	a.filters = make([][][]complex128, a.channels)
+	a.errSpecs = make([][]complex128, a.channels)
+	for ch := range a.filters {
+		a.filters[ch] = make([][]complex128, a.partitions)
+		for k := range a.filters[ch] {
+			a.filters[ch][k] = make([]complex128, a.fft.Size())
+		}
+		a.errSpecs[ch] = make([]complex128, a.fft.Size())
+	}
+	a.Reset()
+}
+
+// Reset forgets the far-end history, delay estimate and echo path
+func (a *AEC) Reset() {
+	clear(a.far)
+	a.farWritten = 0
+	a.reference = false
+	a.resetFilters()
+	a.estimator.reset()
+	a.delay = 0
+	a.estimatedDelay.Store(-1)
+	a.blocks = 0
+	a.dtHold = 0
+	a.doubleTalk.Store(false)
+}
+
+// Latency returns 0: echo is cancelled without delaying the microphone
+func (a *AEC) Latency() int {
+	return 0
+}
+
+// SetEnabled turns echo cancellation on or off
+func (a *AEC) SetEnabled(enabled bool) {
+	a.enabled.Store(enabled)
+}
+
+// Enabled reports whether echo cancellation is active
+func (a *AEC) Enabled() bool {
+	return a.enabled.Load()
+}
+
+// Delay returns the estimated speaker-to-microphone delay in samples, or -1
+// if it hasn't been found yet
+func (a *AEC) Delay() int {
+	return int(a.estimatedDelay.Load())
+}
+
+// DoubleTalk reports whether near-end speech was detected recently, which
+// pauses adaptation
+func (a *AEC) DoubleTalk() bool {
+	return a.doubleTalk.Load()
+}
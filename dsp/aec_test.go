@@ -0,0 +1,260 @@
+package dsp
+
+import (
+	"flag"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/errakhaoui/noise-canceling/wav"
+)
+
+var updateFixtures = flag.Bool("update", false, "regenerate the WAV test fixtures in testdata")
+
+// Echo fixture layout: the far end talks throughout, the near end only
+// between dtStart and dtEnd. The echo path is a 40ms delay followed by a
+// decaying room response.
+const (
+	fixtureSeconds = 3
+	echoDelay      = 1920             // 40ms
+	dtStart        = 7 * testRate / 4 // 1.75s
+	dtEnd          = 5 * testRate / 2 // 2.5s
+)
+
+// speechLike returns noise shaped like syllables: low-pass filtered and
+// amplitude modulated at a few hertz
+func speechLike(seed int64, samples int, amplitude, syllableHz, phase float64) []float32 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float32, samples)
+	var lowpass float64
+	for i := range out {
+		lowpass = 0.7*lowpass + 0.3*rng.NormFloat64()
+		envelope := math.Abs(math.Sin(2*math.Pi*syllableHz*float64(i)/testRate + phase))
+		out[i] = float32(amplitude * envelope * lowpass)
+	}
+	return out
+}
+
+// roomResponse returns the synthetic echo path
+func roomResponse() []float64 {
+	rng := rand.New(rand.NewSource(3))
+	response := make([]float64, echoDelay+1440) // 30ms of reflections
+	response[echoDelay] = 0.25
+	for i := echoDelay + 1; i < len(response); i++ {
+		response[i] = 0.01 * rng.NormFloat64() * math.Exp(-float64(i-echoDelay)/384)
+	}
+	return response
+}
+
+// writeEchoFixtures generates far.wav, near.wav and mic.wav
+func writeEchoFixtures(t *testing.T, dir string) {
+	samples := fixtureSeconds * testRate
+	far := speechLike(1, samples, 0.6, 2.5, 0)
+	near := speechLike(2, samples, 0.4, 1.7, 1)
+	for i := range near {
+		if i < dtStart || i >= dtEnd {
+			near[i] = 0
+		}
+	}
+
+	response := roomResponse()
+	rng := rand.New(rand.NewSource(4))
+	mic := make([]float32, samples)
+	for i := range mic {
+		var echo float64
+		for k, h := range response {
+			if h != 0 && i-k >= 0 {
+				echo += h * float64(far[i-k])
+			}
+		}
+		mic[i] = float32(echo) + near[i] + float32(1e-4*rng.NormFloat64())
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, samples := range map[string][]float32{"far.wav": far, "near.wav": near, "mic.wav": mic} {
+		if err := wav.Write(filepath.Join(dir, name), &wav.Audio{SampleRate: testRate, Channels: 1, Samples: samples}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// loadEchoFixtures reads the echo fixtures, regenerating them with -update
+func loadEchoFixtures(t *testing.T) (far, near, mic []float32) {
+	dir := filepath.Join("testdata", "aec")
+	if *updateFixtures {
+		writeEchoFixtures(t, dir)
+	}
+
+	load := func(name string) []float32 {
+		audio, err := wav.Read(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%v (run go test -update to create the fixtures)", err)
+		}
+		if audio.SampleRate != testRate || audio.Channels != 1 {
+			t.Fatalf("%s is %d Hz with %d channels, want %d Hz mono", name, audio.SampleRate, audio.Channels, testRate)
+		}
+		return audio.Samples
+	}
+	return load("far.wav"), load("near.wav"), load("mic.wav")
+}
+
+// runAEC processes the microphone signal in 10ms frames and returns the
+// output and per-frame double-talk decisions
+func runAEC(t *testing.T, a *AEC, far, mic []float32) ([]float32, []bool) {
+	out := make([]float32, len(mic))
+	copy(out, mic)
+	doubleTalk := make([]bool, len(mic)/480)
+	for f := range doubleTalk {
+		if err := a.SetReference(far[f*480 : (f+1)*480]); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Process(out[f*480 : (f+1)*480]); err != nil {
+			t.Fatal(err)
+		}
+		doubleTalk[f] = a.DoubleTalk()
+	}
+	return out, doubleTalk
+}
+
+// energy returns the sum of squares of x between start and end
+func energy(x []float32, start, end int) float64 {
+	var sum float64
+	for _, sample := range x[start:end] {
+		sum += float64(sample) * float64(sample)
+	}
+	return sum
+}
+
+func TestAECCancelsEcho(t *testing.T) {
+	far, near, mic := loadEchoFixtures(t)
+	a := NewAEC(testRate, 1, DefaultAECConfig())
+	out, doubleTalk := runAEC(t, a, far, mic)
+
+	t.Run("Delay", func(t *testing.T) {
+		if delay := a.Delay(); abs(delay-echoDelay) > 2*delayDecimation {
+			t.Errorf("Delay() = %d samples, want %d", delay, echoDelay)
+		}
+	})
+
+	// Echo return loss enhancement once converged (the delay is found after
+	// about 0.75s), before the near end talks
+	t.Run("EchoOnly", func(t *testing.T) {
+		start := 5 * testRate / 4
+		erle := 10 * math.Log10(energy(mic, start, dtStart)/energy(out, start, dtStart))
+		if erle < 20 {
+			t.Errorf("echo reduced by %.1f dB, want at least 20 dB", erle)
+		}
+		for f := testRate / 480; f < dtStart/480; f++ {
+			if doubleTalk[f] {
+				t.Errorf("double talk detected at %.2fs with only echo present", float64(f)/100)
+				break
+			}
+		}
+	})
+
+	// The near end must come through, and the filter must not be disturbed
+	t.Run("DoubleTalk", func(t *testing.T) {
+		detected, speaking := 0, 0
+		for f := dtStart / 480; f < dtEnd/480; f++ {
+			if energy(near, f*480, (f+1)*480) > 480*0.01*0.01 {
+				speaking++
+				if doubleTalk[f] {
+					detected++
+				}
+			}
+		}
+		if detected < speaking*9/10 {
+			t.Errorf("double talk detected in %d of %d frames with near-end speech", detected, speaking)
+		}
+
+		var residual float64
+		for i := dtStart; i < dtEnd; i++ {
+			d := float64(out[i] - near[i])
+			residual += d * d
+		}
+		if snr := 10 * math.Log10(energy(near, dtStart, dtEnd)/residual); snr < 15 {
+			t.Errorf("near-end speech to residual echo ratio %.1f dB during double talk, want at least 15 dB", snr)
+		}
+	})
+
+	t.Run("AfterDoubleTalk", func(t *testing.T) {
+		start := dtEnd + 10*480 // Past the detector's hold time
+		erle := 10 * math.Log10(energy(mic, start, len(mic))/energy(out, start, len(mic)))
+		if erle < 20 {
+			t.Errorf("echo reduced by %.1f dB after double talk, want at least 20 dB", erle)
+		}
+	})
+}
+
+func TestAECWithoutReference(t *testing.T) {
+	a := NewAEC(testRate, 2, DefaultAECConfig())
+	_, _, mic := loadEchoFixtures(t)
+
+	frame := make([]float32, 960)
+	for f := 0; f < 50; f++ {
+		for i := 0; i < 480; i++ {
+			frame[2*i], frame[2*i+1] = mic[f*480+i], -mic[f*480+i]
+		}
+		if err := a.Process(frame); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 480; i++ {
+			if frame[2*i] != mic[f*480+i] || frame[2*i+1] != -mic[f*480+i] {
+				t.Fatalf("frame %d changed without a reference", f)
+			}
+		}
+	}
+	if a.Delay() != -1 {
+		t.Errorf("Delay() = %d without a reference, want -1", a.Delay())
+	}
+}
+
+func TestAECRejectsWrongSizes(t *testing.T) {
+	a := NewAEC(testRate, 1, DefaultAECConfig())
+	if err := a.SetReference(make([]float32, 100)); err == nil {
+		t.Error("SetReference with a short block succeeded, want error")
+	}
+	if err := a.Process(make([]float32, 960)); err == nil {
+		t.Error("Process with a stereo frame on a mono AEC succeeded, want error")
+	}
+}
+
+func TestAECDoesNotAllocate(t *testing.T) {
+	a := NewAEC(testRate, 2, DefaultAECConfig())
+	reference := speechLike(1, 480, 0.5, 2.5, 0)
+	frame := make([]float32, 960)
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = a.SetReference(reference)
+		_ = a.Process(frame)
+	})
+	if allocs != 0 {
+		t.Errorf("AEC allocates %.1f times per frame, want 0", allocs)
+	}
+}
+
+// TestAECDelayEstimatorIsSmall keeps delay estimation cheap enough to run on
+// the audio thread with the default settings
+func TestAECDelayEstimatorIsSmall(t *testing.T) {
+	a := NewAEC(testRate, 1, DefaultAECConfig())
+	if size := a.estimator.fft.Size(); size > 4096 {
+		t.Errorf("delay estimation FFT has %d points, want at most 4096", size)
+	}
+}
+
+func BenchmarkAECDelayEstimate(b *testing.B) {
+	a := NewAEC(testRate, 1, DefaultAECConfig())
+	reference := speechLike(1, 480, 0.5, 2.5, 0)
+	for i := 0; i < 100; i++ {
+		for _, sample := range reference {
+			a.estimator.add(sample, sample)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.estimator.estimate()
+	}
+}
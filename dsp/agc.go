@@ -0,0 +1,194 @@
+package dsp
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// VoiceSource reports whether the current frame contains speech, typically
+// from the denoiser's voice activity detector
+type VoiceSource interface {
+	// VoiceProbability returns the speech probability (0-1) of the most
+	// recent frame; ok is false when no estimate is available
+	VoiceProbability() (probability float32, ok bool)
+}
+
+// AGCConfig configures the automatic gain control
+type AGCConfig struct {
+	// TargetDB is the speech RMS level the AGC aims for, in dBFS
+	TargetDB float32
+	// MaxGainDB limits how far quiet speech is boosted
+	MaxGainDB float32
+	// Attack is how quickly gain drops when speech gets louder
+	Attack time.Duration
+	// Release is how quickly gain rises when speech gets quieter
+	Release time.Duration
+	// VoiceThreshold is the voice probability below which the gain is frozen
+	VoiceThreshold float32
+}
+
+// DefaultAGCConfig returns settings suited to conversational speech
+func DefaultAGCConfig() AGCConfig {
+	return AGCConfig{
+		TargetDB:       -18,
+		MaxGainDB:      18,
+		Attack:         50 * time.Millisecond,
+		Release:        800 * time.Millisecond,
+		VoiceThreshold: 0.6,
+	}
+}
+
+// AGC is a Processor that brings speech towards a target level. The gain
+// only adapts while the VoiceSource reports speech, so pauses don't pump up
+// residual noise; while it has no estimate (e.g. the denoiser is bypassed)
+// the gain is held.
+type AGC struct {
+	channels       int
+	attackCoef     float64
+	releaseCoef    float64
+	voiceThreshold float32
+	voice          VoiceSource
+
+	enabled  atomic.Bool
+	target   atomic.Uint32 // float32 bits, linear RMS
+	maxGain  atomic.Uint32 // float32 bits, linear
+	gainBits atomic.Uint32 // float32 bits, gain at the end of the last frame
+
+	gain    float64
+	desired float64
+}
+
+var _ Processor = (*AGC)(nil)
+
+// NewAGC creates an enabled AGC for interleaved audio with the given sample
+// rate and channel count. voice may be nil, in which case the gain always
+// adapts.
+func NewAGC(sampleRate, channels int, cfg AGCConfig, voice VoiceSource) *AGC {
+	a := &AGC{
+		channels:       max(1, channels),
+		attackCoef:     smoothingCoef(cfg.Attack, sampleRate),
+		releaseCoef:    smoothingCoef(cfg.Release, sampleRate),
+		voiceThreshold: cfg.VoiceThreshold,
+		voice:          voice,
+	}
+	a.SetTargetDB(cfg.TargetDB)
+	a.SetMaxGainDB(cfg.MaxGainDB)
+	a.enabled.Store(true)
+	a.Reset()
+	return a
+}
+
+// smoothingCoef returns the per-sample one-pole coefficient for a time
+// constant
+func smoothingCoef(tau time.Duration, sampleRate int) float64 {
+	samples := tau.Seconds() * float64(sampleRate)
+	if samples <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / samples)
+}
+
+// Process applies the AGC gain to one frame in place
+func (a *AGC) Process(frame []float32) error {
+	if a.enabled.Load() {
+		a.updateDesired(frame)
+	} else {
+		a.desired = 1 // Glide back to unity instead of jumping
+	}
+
+	// Smooth towards the desired gain sample by sample so changes are
+	// inaudible
+	for i := 0; i < len(frame); i += a.channels {
+		coef := a.releaseCoef
+		if a.desired < a.gain {
+			coef = a.attackCoef
+		}
+		a.gain = a.desired + (a.gain-a.desired)*coef
+
+		end := min(i+a.channels, len(frame))
+		for c := i; c < end; c++ {
+			frame[c] *= float32(a.gain)
+		}
+	}
+
+	a.gainBits.Store(math.Float32bits(float32(a.gain)))
+	return nil
+}
+
+// updateDesired picks the gain that brings this frame to the target level,
+// unless the frame isn't known to be speech
+func (a *AGC) updateDesired(frame []float32) {
+	if a.voice != nil {
+		// Freeze while nobody is speaking, or nobody can tell
+		if probability, ok := a.voice.VoiceProbability(); !ok || probability < a.voiceThreshold {
+			return
+		}
+	}
+
+	var sum float64
+	for _, sample := range frame {
+		sum += float64(sample) * float64(sample)
+	}
+	if len(frame) == 0 || sum == 0 {
+		return
+	}
+
+	rms := math.Sqrt(sum / float64(len(frame)))
+	maxGain := float64(math.Float32frombits(a.maxGain.Load()))
+	target := float64(math.Float32frombits(a.target.Load()))
+	a.desired = math.Min(target/rms, maxGain)
+}
+
+// SetChannels changes the number of interleaved channels per frame. Must not
+// be called while Process is running.
+func (a *AGC) SetChannels(channels int) {
+	a.channels = max(1, channels)
+}
+
+// Reset returns the gain to unity
+func (a *AGC) Reset() {
+	a.gain = 1
+	a.desired = 1
+	a.gainBits.Store(math.Float32bits(1))
+}
+
+// Latency returns 0: the AGC adds no delay
+func (a *AGC) Latency() int {
+	return 0
+}
+
+// SetEnabled turns the AGC on or off; when off the gain glides back to unity
+func (a *AGC) SetEnabled(enabled bool) {
+	a.enabled.Store(enabled)
+}
+
+// Enabled reports whether the AGC is adapting its gain
+func (a *AGC) Enabled() bool {
+	return a.enabled.Load()
+}
+
+// SetTargetDB sets the target speech level in dBFS
+func (a *AGC) SetTargetDB(db float32) {
+	a.target.Store(math.Float32bits(dbToLinear(db)))
+}
+
+// SetMaxGainDB sets the largest boost in dB (negative values are treated as 0)
+func (a *AGC) SetMaxGainDB(db float32) {
+	a.maxGain.Store(math.Float32bits(dbToLinear(max(0, db))))
+}
+
+// GainDB returns the gain applied at the end of the last frame, in dB
+func (a *AGC) GainDB() float32 {
+	return linearToDB(math.Float32frombits(a.gainBits.Load()))
+}
+
+// dbToLinear converts decibels to an amplitude ratio
+func dbToLinear(db float32) float32 {
+	return float32(math.Pow(10, float64(db)/20))
+}
+
+// linearToDB converts an amplitude ratio to decibels
+func linearToDB(gain float32) float32 {
+	return float32(20 * math.Log10(float64(gain)))
+}
@@ -0,0 +1,153 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// fixedVoice is a VoiceSource with a constant probability
+type fixedVoice float32
+
+func (v fixedVoice) VoiceProbability() (float32, bool) { return float32(v), true }
+
+// bypassedVoice is a VoiceSource with no estimate, like a bypassed denoiser
+type bypassedVoice struct{}
+
+func (bypassedVoice) VoiceProbability() (float32, bool) { return 0, false }
+
+// sineFrames runs frames of a 440 Hz sine at the given dBFS RMS level
+// through the AGC and returns the RMS level of the last frame in dBFS
+func sineFrames(a *AGC, levelDB float64, frames int) float64 {
+	amplitude := math.Pow(10, levelDB/20) * math.Sqrt2
+	frame := make([]float32, 480)
+	n := 0
+	var rms float64
+	for f := 0; f < frames; f++ {
+		for i := range frame {
+			frame[i] = float32(amplitude * math.Sin(2*math.Pi*440*float64(n)/48000))
+			n++
+		}
+		_ = a.Process(frame)
+
+		var sum float64
+		for _, sample := range frame {
+			sum += float64(sample) * float64(sample)
+		}
+		rms = math.Sqrt(sum / float64(len(frame)))
+	}
+	return 20 * math.Log10(rms)
+}
+
+func TestAGCReachesTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputDB float64
+	}{
+		{"QuietSpeaker", -40},
+		{"LoudSpeaker", -6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultAGCConfig()
+			cfg.MaxGainDB = 30
+			a := NewAGC(48000, 1, cfg, fixedVoice(1))
+
+			got := sineFrames(a, tt.inputDB, 500)
+			if math.Abs(got-float64(cfg.TargetDB)) > 1 {
+				t.Errorf("output level %.1f dBFS, want %.1f dBFS", got, cfg.TargetDB)
+			}
+		})
+	}
+}
+
+func TestAGCMaxGain(t *testing.T) {
+	cfg := DefaultAGCConfig()
+	cfg.MaxGainDB = 12
+	a := NewAGC(48000, 1, cfg, fixedVoice(1))
+
+	got := sineFrames(a, -50, 500)
+	if math.Abs(got-(-38)) > 0.5 {
+		t.Errorf("output level %.1f dBFS, want -38 dBFS (input boosted by the 12 dB limit)", got)
+	}
+	if gain := a.GainDB(); math.Abs(float64(gain)-12) > 0.1 {
+		t.Errorf("GainDB() = %.2f, want 12", gain)
+	}
+}
+
+func TestAGCFreezesWithoutSpeech(t *testing.T) {
+	a := NewAGC(48000, 1, DefaultAGCConfig(), fixedVoice(0.1))
+
+	sineFrames(a, -40, 300)
+	if gain := a.GainDB(); gain != 0 {
+		t.Errorf("GainDB() = %.2f while nobody speaks, want 0", gain)
+	}
+}
+
+func TestAGCHoldsWhileVoiceBypassed(t *testing.T) {
+	a := NewAGC(48000, 1, DefaultAGCConfig(), fixedVoice(1))
+	sineFrames(a, -24, 300)
+	held := a.GainDB()
+
+	// Background noise in a pause must not be boosted just because the
+	// denoiser stopped reporting voice activity
+	a.voice = bypassedVoice{}
+	sineFrames(a, -50, 300)
+	if gain := a.GainDB(); math.Abs(float64(gain-held)) > 0.1 {
+		t.Errorf("GainDB() = %.2f while the voice source is bypassed, want it held at %.2f", gain, held)
+	}
+
+	fresh := NewAGC(48000, 1, DefaultAGCConfig(), bypassedVoice{})
+	sineFrames(fresh, -40, 300)
+	if gain := fresh.GainDB(); gain != 0 {
+		t.Errorf("GainDB() = %.2f with a bypassed voice source, want 0", gain)
+	}
+}
+
+func TestAGCDisableGlidesToUnity(t *testing.T) {
+	cfg := DefaultAGCConfig()
+	cfg.Release = 100 * time.Millisecond
+	a := NewAGC(48000, 1, cfg, fixedVoice(1))
+	sineFrames(a, -30, 300)
+
+	a.SetEnabled(false)
+	sineFrames(a, -30, 300)
+	if gain := a.GainDB(); math.Abs(float64(gain)) > 0.1 {
+		t.Errorf("GainDB() = %.2f after disabling, want 0", gain)
+	}
+}
+
+func TestAGCGainChangesSmoothly(t *testing.T) {
+	a := NewAGC(48000, 2, DefaultAGCConfig(), fixedVoice(1))
+
+	// A step from quiet to loud: the gain may fall quickly but never jump
+	frame := make([]float32, 960)
+	for f := 0; f < 200; f++ {
+		level := float32(0.01)
+		if f >= 100 {
+			level = 0.5
+		}
+		for i := range frame {
+			frame[i] = level
+		}
+		_ = a.Process(frame)
+
+		for i := 2; i < len(frame); i += 2 {
+			if frame[i] != frame[i+1] {
+				t.Fatalf("frame %d: channels got different gains", f)
+			}
+			if step := math.Abs(float64(frame[i]-frame[i-2])) / float64(level); step > 0.01 {
+				t.Fatalf("frame %d sample %d: gain jumped by %.3f", f, i, step)
+			}
+		}
+	}
+}
+
+func TestAGCDoesNotAllocate(t *testing.T) {
+	a := NewAGC(48000, 1, DefaultAGCConfig(), fixedVoice(1))
+	frame := make([]float32, 480)
+	if allocs := testing.AllocsPerRun(100, func() { _ = a.Process(frame) }); allocs != 0 {
+		t.Errorf("AGC.Process allocates %.1f times per frame, want 0", allocs)
+	}
+}
@@ -0,0 +1,199 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"sync/atomic"
+)
+
+// BiquadCoefficients are the normalised (a0 = 1) coefficients of a
+// second-order IIR filter:
+//
+//	H(z) = (B0 + B1 z^-1 + B2 z^-2) / (1 + A1 z^-1 + A2 z^-2)
+type BiquadCoefficients struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+}
+
+// Designs follow Robert Bristow-Johnson's Audio EQ Cookbook.
+
+// HighPassCoefficients returns a second-order high-pass filter. A q of
+// 1/sqrt(2) gives a Butterworth response.
+func HighPassCoefficients(sampleRate int, cutoff, q float64) BiquadCoefficients {
+	cos, alpha := cookbookTerms(sampleRate, cutoff, q)
+	return normalise(
+		(1+cos)/2, -(1 + cos), (1+cos)/2,
+		1+alpha, -2*cos, 1-alpha,
+	)
+}
+
+// LowPassCoefficients returns a second-order low-pass filter
+func LowPassCoefficients(sampleRate int, cutoff, q float64) BiquadCoefficients {
+	cos, alpha := cookbookTerms(sampleRate, cutoff, q)
+	return normalise(
+		(1-cos)/2, 1-cos, (1-cos)/2,
+		1+alpha, -2*cos, 1-alpha,
+	)
+}
+
+// PeakingCoefficients returns a bell filter boosting or cutting gainDB
+// around center
+func PeakingCoefficients(sampleRate int, center, q, gainDB float64) BiquadCoefficients {
+	cos, alpha := cookbookTerms(sampleRate, center, q)
+	a := math.Pow(10, gainDB/40)
+	return normalise(
+		1+alpha*a, -2*cos, 1-alpha*a,
+		1+alpha/a, -2*cos, 1-alpha/a,
+	)
+}
+
+// LowShelfCoefficients returns a shelf changing frequencies below corner by
+// gainDB
+func LowShelfCoefficients(sampleRate int, corner, q, gainDB float64) BiquadCoefficients {
+	cos, alpha := cookbookTerms(sampleRate, corner, q)
+	a := math.Pow(10, gainDB/40)
+	root := 2 * math.Sqrt(a) * alpha
+	return normalise(
+		a*((a+1)-(a-1)*cos+root), 2*a*((a-1)-(a+1)*cos), a*((a+1)-(a-1)*cos-root),
+		(a+1)+(a-1)*cos+root, -2*((a-1)+(a+1)*cos), (a+1)+(a-1)*cos-root,
+	)
+}
+
+// HighShelfCoefficients returns a shelf changing frequencies above corner by
+// gainDB
+func HighShelfCoefficients(sampleRate int, corner, q, gainDB float64) BiquadCoefficients {
+	cos, alpha := cookbookTerms(sampleRate, corner, q)
+	a := math.Pow(10, gainDB/40)
+	root := 2 * math.Sqrt(a) * alpha
+	return normalise(
+		a*((a+1)+(a-1)*cos+root), -2*a*((a-1)+(a+1)*cos), a*((a+1)+(a-1)*cos-root),
+		(a+1)-(a-1)*cos+root, 2*((a-1)-(a+1)*cos), (a+1)-(a-1)*cos-root,
+	)
+}
+
+// NotchCoefficients returns a band-stop filter removing center, with a
+// bandwidth of center/q
+func NotchCoefficients(sampleRate int, center, q float64) BiquadCoefficients {
+	cos, alpha := cookbookTerms(sampleRate, center, q)
+	return normalise(
+		1, -2*cos, 1,
+		1+alpha, -2*cos, 1-alpha,
+	)
+}
+
+// cookbookTerms returns cos(w0) and alpha for a design frequency
+func cookbookTerms(sampleRate int, freq, q float64) (float64, float64) {
+	w0 := 2 * math.Pi * freq / float64(sampleRate)
+	return math.Cos(w0), math.Sin(w0) / (2 * q)
+}
+
+// normalise divides all coefficients by a0
+func normalise(b0, b1, b2, a0, a1, a2 float64) BiquadCoefficients {
+	return BiquadCoefficients{
+		B0: b0 / a0, B1: b1 / a0, B2: b2 / a0,
+		A1: a1 / a0, A2: a2 / a0,
+	}
+}
+
+// Response returns the complex frequency response at freq
+func (c BiquadCoefficients) Response(sampleRate int, freq float64) complex128 {
+	z1 := cmplx.Exp(complex(0, -2*math.Pi*freq/float64(sampleRate)))
+	z2 := z1 * z1
+	num := complex(c.B0, 0) + complex(c.B1, 0)*z1 + complex(c.B2, 0)*z2
+	den := 1 + complex(c.A1, 0)*z1 + complex(c.A2, 0)*z2
+	return num / den
+}
+
+// MagnitudeDB returns the gain in dB at freq
+func (c BiquadCoefficients) MagnitudeDB(sampleRate int, freq float64) float64 {
+	return 20 * math.Log10(cmplx.Abs(c.Response(sampleRate, freq)))
+}
+
+// Biquad is a Processor running one second-order filter on every channel of
+// interleaved audio. Coefficients can be swapped while audio is running.
+type Biquad struct {
+	channels int
+	coeffs   atomic.Pointer[BiquadCoefficients]
+	enabled  atomic.Bool
+	active   bool // enabled state seen by the last Process call
+
+	// Transposed direct form II state per channel
+	z1, z2 []float64
+}
+
+var _ Processor = (*Biquad)(nil)
+
+// NewBiquad creates an enabled filter for interleaved audio with the given
+// channel count
+func NewBiquad(channels int, coeffs BiquadCoefficients) *Biquad {
+	b := &Biquad{}
+	b.SetChannels(channels)
+	b.SetCoefficients(coeffs)
+	b.enabled.Store(true)
+	b.active = true
+	return b
+}
+
+// SetCoefficients replaces the filter coefficients from the next frame on
+func (b *Biquad) SetCoefficients(coeffs BiquadCoefficients) {
+	b.coeffs.Store(&coeffs)
+}
+
+// Coefficients returns the current coefficients
+func (b *Biquad) Coefficients() BiquadCoefficients {
+	return *b.coeffs.Load()
+}
+
+// SetEnabled turns the filter on or off; a disabled filter passes audio
+// through unchanged
+func (b *Biquad) SetEnabled(enabled bool) {
+	b.enabled.Store(enabled)
+}
+
+// Enabled reports whether the filter is active
+func (b *Biquad) Enabled() bool {
+	return b.enabled.Load()
+}
+
+// SetChannels changes the number of interleaved channels and clears the
+// filter state. Must not be called while Process is running.
+func (b *Biquad) SetChannels(channels int) {
+	b.channels = max(1, channels)
+	b.z1 = make([]float64, b.channels)
+	b.z2 = make([]float64, b.channels)
+}
+
+// Process filters one interleaved frame in place
+func (b *Biquad) Process(frame []float32) error {
+	enabled := b.enabled.Load()
+	if enabled != b.active {
+		// Don't let stale state from before the bypass ring out
+		b.active = enabled
+		b.Reset()
+	}
+	if !enabled {
+		return nil
+	}
+
+	c := b.coeffs.Load()
+	for i, sample := range frame {
+		ch := i % b.channels
+		x := float64(sample)
+		y := c.B0*x + b.z1[ch]
+		b.z1[ch] = c.B1*x - c.A1*y + b.z2[ch]
+		b.z2[ch] = c.B2*x - c.A2*y
+		frame[i] = float32(y)
+	}
+	return nil
+}
+
+// Reset clears the filter state
+func (b *Biquad) Reset() {
+	clear(b.z1)
+	clear(b.z2)
+}
+
+// Latency returns 0: biquads are causal IIR filters with no block delay
+func (b *Biquad) Latency() int {
+	return 0
+}
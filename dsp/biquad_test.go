@@ -0,0 +1,181 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+const testRate = 48000
+
+// prewarp maps a frequency to the bilinear transform's analog axis
+func prewarp(freq float64) float64 {
+	return math.Tan(math.Pi * freq / testRate)
+}
+
+func TestBiquadButterworthResponse(t *testing.T) {
+	const cutoff = 80.0
+	hp := HighPassCoefficients(testRate, cutoff, butterworthQ)
+	lp := LowPassCoefficients(testRate, 4000, butterworthQ)
+
+	// Bilinear-transformed second-order Butterworth:
+	// |H_hp|^2 = W^4/(W^4+Wc^4), |H_lp|^2 = Wc^4/(W^4+Wc^4)
+	for _, freq := range []float64{5, 20, 40, 80, 160, 1000, 4000, 10000, 20000} {
+		w := math.Pow(prewarp(freq), 4)
+
+		wc := math.Pow(prewarp(cutoff), 4)
+		want := 10 * math.Log10(w/(w+wc))
+		if got := hp.MagnitudeDB(testRate, freq); math.Abs(got-want) > 1e-6 {
+			t.Errorf("high-pass at %g Hz = %.6f dB, want %.6f dB", freq, got, want)
+		}
+
+		wc = math.Pow(prewarp(4000), 4)
+		want = 10 * math.Log10(wc/(w+wc))
+		if got := lp.MagnitudeDB(testRate, freq); math.Abs(got-want) > 1e-6 {
+			t.Errorf("low-pass at %g Hz = %.6f dB, want %.6f dB", freq, got, want)
+		}
+	}
+
+	if got := hp.MagnitudeDB(testRate, cutoff); math.Abs(got+10*math.Log10(2)) > 1e-9 {
+		t.Errorf("high-pass at cutoff = %.4f dB, want -3.01 dB", got)
+	}
+	if dc := cmplxAbs(hp.Response(testRate, 0)); dc > 1e-12 {
+		t.Errorf("high-pass DC gain = %g, want 0", dc)
+	}
+}
+
+func TestBiquadEQResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		coeffs BiquadCoefficients
+		freq   float64
+		wantDB float64
+	}{
+		{"PeakingCenter", PeakingCoefficients(testRate, 3000, 1.4, 6), 3000, 6},
+		{"PeakingCut", PeakingCoefficients(testRate, 500, 2, -9), 500, -9},
+		{"LowShelfDC", LowShelfCoefficients(testRate, 200, butterworthQ, -6), 0, -6},
+		{"LowShelfNyquist", LowShelfCoefficients(testRate, 200, butterworthQ, -6), testRate / 2, 0},
+		{"LowShelfCorner", LowShelfCoefficients(testRate, 200, butterworthQ, -6), 200, -3},
+		{"HighShelfNyquist", HighShelfCoefficients(testRate, 8000, butterworthQ, 4), testRate / 2, 4},
+		{"HighShelfDC", HighShelfCoefficients(testRate, 8000, butterworthQ, 4), 0, 0},
+		{"HighShelfCorner", HighShelfCoefficients(testRate, 8000, butterworthQ, 4), 8000, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.coeffs.MagnitudeDB(testRate, tt.freq); math.Abs(got-tt.wantDB) > 1e-6 {
+				t.Errorf("gain at %g Hz = %.6f dB, want %.6f dB", tt.freq, got, tt.wantDB)
+			}
+		})
+	}
+}
+
+// TestBiquadBandResponse compares peaking and notch filters with their
+// analog prototypes, evaluated at prewarped frequencies
+func TestBiquadBandResponse(t *testing.T) {
+	const center, q = 1000.0, 2.0
+	a := math.Pow(10, 6.0/40)
+	peaking := PeakingCoefficients(testRate, center, q, 6)
+	notch := NotchCoefficients(testRate, center, q)
+
+	for _, freq := range []float64{50, 300, 700, 900, 1000, 1100, 1500, 4000, 15000} {
+		w := prewarp(freq) / prewarp(center)
+		base := (1 - w*w) * (1 - w*w)
+
+		// H(s) = (s^2 + s*A/Q + 1) / (s^2 + s/(A*Q) + 1)
+		want := 10 * math.Log10((base+math.Pow(w*a/q, 2))/(base+math.Pow(w/(a*q), 2)))
+		if got := peaking.MagnitudeDB(testRate, freq); math.Abs(got-want) > 1e-6 {
+			t.Errorf("peaking at %g Hz = %.6f dB, want %.6f dB", freq, got, want)
+		}
+
+		if freq == center {
+			continue // The notch's gain is exactly zero there
+		}
+		// H(s) = (s^2 + 1) / (s^2 + s/Q + 1)
+		want = 10 * math.Log10(base/(base+math.Pow(w/q, 2)))
+		if got := notch.MagnitudeDB(testRate, freq); math.Abs(got-want) > 1e-6 {
+			t.Errorf("notch at %g Hz = %.6f dB, want %.6f dB", freq, got, want)
+		}
+	}
+
+	if got := cmplxAbs(notch.Response(testRate, center)); got > 1e-9 {
+		t.Errorf("notch gain at center = %g, want 0", got)
+	}
+}
+
+// TestBiquadProcessMatchesResponse runs sines through the filter and checks
+// the steady-state output against the gain and phase predicted by Response
+func TestBiquadProcessMatchesResponse(t *testing.T) {
+	coeffs := PeakingCoefficients(testRate, 1000, 1, 9)
+
+	for _, freq := range []float64{100, 1000, 5000} {
+		b := NewBiquad(1, coeffs)
+		w := 2 * math.Pi * freq / testRate
+		signal := make([]float32, testRate/2)
+		for i := range signal {
+			signal[i] = float32(0.5 * math.Sin(w*float64(i)))
+		}
+		_ = b.Process(signal)
+
+		// Compare the last 100ms, once start-up transients have died away
+		response := coeffs.Response(testRate, freq)
+		gain, phase := cmplxAbs(response), math.Atan2(imag(response), real(response))
+		for i := len(signal) - testRate/10; i < len(signal); i++ {
+			want := 0.5 * gain * math.Sin(w*float64(i)+phase)
+			if math.Abs(float64(signal[i])-want) > 1e-4 {
+				t.Fatalf("%g Hz sample %d = %.5f, want %.5f", freq, i, signal[i], want)
+			}
+		}
+	}
+}
+
+func TestHighPassRemovesDC(t *testing.T) {
+	h := NewHighPass(testRate, 2, DefaultHighPassCutoff)
+	frame := make([]float32, 960)
+
+	for f := 0; f < 50; f++ {
+		for i := range frame {
+			frame[i] = 0.2 // Constant offset on both channels
+		}
+		_ = h.Process(frame)
+	}
+	for i, sample := range frame {
+		if math.Abs(float64(sample)) > 1e-4 {
+			t.Fatalf("sample %d = %v after 0.5s, want DC removed", i, sample)
+		}
+	}
+}
+
+func TestHighPassSetCutoff(t *testing.T) {
+	h := NewHighPass(testRate, 1, DefaultHighPassCutoff)
+	h.SetCutoff(120)
+
+	if h.Cutoff() != 120 {
+		t.Errorf("Cutoff() = %v, want 120", h.Cutoff())
+	}
+	if got := h.Coefficients().MagnitudeDB(testRate, 120); math.Abs(got+10*math.Log10(2)) > 1e-9 {
+		t.Errorf("gain at new cutoff = %.4f dB, want -3.01 dB", got)
+	}
+}
+
+func TestBiquadBypass(t *testing.T) {
+	b := NewBiquad(1, HighPassCoefficients(testRate, 1000, butterworthQ))
+	b.SetEnabled(false)
+
+	frame := []float32{0.1, 0.2, 0.3}
+	_ = b.Process(frame)
+	if frame[0] != 0.1 || frame[1] != 0.2 || frame[2] != 0.3 {
+		t.Errorf("bypassed filter changed audio: %v", frame)
+	}
+}
+
+func TestBiquadDoesNotAllocate(t *testing.T) {
+	b := NewBiquad(2, PeakingCoefficients(testRate, 1000, 1, 3))
+	frame := make([]float32, 960)
+	if allocs := testing.AllocsPerRun(100, func() { _ = b.Process(frame) }); allocs != 0 {
+		t.Errorf("Biquad.Process allocates %.1f times per frame, want 0", allocs)
+	}
+}
+
+func cmplxAbs(x complex128) float64 {
+	return math.Hypot(real(x), imag(x))
+}
@@ -0,0 +1,269 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// clickDetectCutoff is where the detector's high-pass sits; key clicks
+	// are broadband while plosives carry most of their energy below it
+	clickDetectCutoff = 2000
+	// clickFast is the time constant of the envelope that catches onsets
+	clickFast = 500 * time.Microsecond
+	// clickBackground is the time constant of the background level the
+	// onsets are compared with
+	clickBackground = 200 * time.Millisecond
+	// clickSettle is how long the background is tracked before anything is
+	// treated as a click
+	clickSettle = 50 * time.Millisecond
+	// clickFloorDB is the quietest detector level considered, so clicks
+	// aren't found in digital silence
+	clickFloorDB = -70
+	// clickMaxThresholdDB and clickMinThresholdDB are how far an onset must
+	// rise above the background at sensitivity 0 and 1
+	clickMaxThresholdDB = 30
+	clickMinThresholdDB = 10
+)
+
+// ClickConfig configures a ClickSuppressor
+type ClickConfig struct {
+	// Sensitivity (0-1) sets how small a transient is treated as a click
+	Sensitivity float32
+	// DepthDB is how far clicks are turned down
+	DepthDB float32
+	// Hold is how long the gain stays down after the last detected onset,
+	// covering the ring of a key after its attack
+	Hold time.Duration
+	// Release is how quickly the gain recovers after the hold
+	Release time.Duration
+	// Lookahead lets the gain drop before a click's attack arrives; it is
+	// also the delay the suppressor adds
+	Lookahead time.Duration
+	// VoiceThreshold is the voice probability above which clicks must also
+	// clear VoiceGuardDB, so plosives and sibilants aren't mistaken for them
+	VoiceThreshold float32
+	// VoiceGuardDB is added to the detection threshold while speaking
+	VoiceGuardDB float32
+}
+
+// DefaultClickConfig returns settings that catch mechanical keyboard clicks
+// without touching speech
+func DefaultClickConfig() ClickConfig {
+	return ClickConfig{
+		Sensitivity:    0.5,
+		DepthDB:        24,
+		Hold:           15 * time.Millisecond,
+		Release:        5 * time.Millisecond,
+		Lookahead:      2 * time.Millisecond,
+		VoiceThreshold: 0.6,
+		VoiceGuardDB:   15,
+	}
+}
+
+// ClickSuppressor is a Processor that ducks short broadband transients such
+// as keyboard and mouse clicks, which RNNoise only partly removes. Onsets are
+// detected on the high frequencies of a mono mix and compared against the
+// background level; while the VoiceSource reports speech they must stand out
+// further, so consonants pass untouched. All channels share one gain.
+//
+// Like the Limiter, the audio is delayed by the lookahead even while
+// disabled, so toggling doesn't change the latency.
+type ClickSuppressor struct {
+	channels       int
+	lookahead      int // in samples per channel
+	hold           int // in samples
+	settle         int // in samples
+	depth          float64
+	fastCoef       float64
+	backgroundCoef float64
+	settleCoef     float64
+	attackCoef     float64
+	releaseCoef    float64
+	voiceThreshold float32
+	voiceGuard     float64
+	voice          VoiceSource
+
+	enabled     atomic.Bool
+	sensitivity atomic.Uint32 // float32 bits
+	clicks      atomic.Uint64
+
+	highpass   BiquadCoefficients
+	hpState    [2]float64
+	fast       float64 // detector power envelope
+	background float64 // detector background level in dB
+	settled    int
+	holding    int // samples the gain stays down for
+	gain       float64
+	delay      []float32 // interleaved ring of lookahead sample frames
+	delayPos   int
+}
+
+var _ Processor = (*ClickSuppressor)(nil)
+
+// NewClickSuppressor creates an enabled ClickSuppressor for interleaved audio
+// with the given sample rate and channel count. voice may be nil, in which
+// case speech gets no extra protection.
+func NewClickSuppressor(sampleRate, channels int, cfg ClickConfig, voice VoiceSource) *ClickSuppressor {
+	lookahead := max(1, int(cfg.Lookahead.Seconds()*float64(sampleRate)))
+	s := &ClickSuppressor{
+		lookahead:      lookahead,
+		hold:           int(cfg.Hold.Seconds() * float64(sampleRate)),
+		settle:         int(clickSettle.Seconds() * float64(sampleRate)),
+		depth:          math.Pow(10, -float64(cfg.DepthDB)/20),
+		fastCoef:       smoothingCoef(clickFast, sampleRate),
+		backgroundCoef: smoothingCoef(clickBackground, sampleRate),
+		settleCoef:     smoothingCoef(clickSettle/10, sampleRate),
+		// Reach the full depth by the time the onset leaves the delay line
+		attackCoef:     math.Exp(-4 / float64(lookahead)),
+		releaseCoef:    smoothingCoef(cfg.Release, sampleRate),
+		voiceThreshold: cfg.VoiceThreshold,
+		voiceGuard:     float64(cfg.VoiceGuardDB),
+		voice:          voice,
+		highpass:       HighPassCoefficients(sampleRate, clickDetectCutoff, butterworthQ),
+	}
+	s.SetSensitivity(cfg.Sensitivity)
+	s.enabled.Store(true)
+	s.SetChannels(channels)
+	return s
+}
+
+// SetChannels changes the number of interleaved channels per frame and clears
+// the delay line. Must not be called while Process is running.
+func (s *ClickSuppressor) SetChannels(channels int) {
+	s.channels = max(1, channels)
+	s.delay = make([]float32, s.lookahead*s.channels)
+	s.Reset()
+}
+
+// Process suppresses clicks in one interleaved frame in place. The output is
+// delayed by the lookahead.
+func (s *ClickSuppressor) Process(frame []float32) error {
+	if len(frame)%s.channels != 0 {
+		return fmt.Errorf("click suppressor expects a multiple of %d samples, got %d", s.channels, len(frame))
+	}
+
+	enabled := s.enabled.Load()
+	threshold := s.thresholdDB()
+	if s.voice != nil {
+		if probability, ok := s.voice.VoiceProbability(); ok && probability >= s.voiceThreshold {
+			threshold += s.voiceGuard
+		}
+	}
+
+	scale := 1 / float64(s.channels)
+	for i := 0; i < len(frame); i += s.channels {
+		var mono float64
+		for _, sample := range frame[i : i+s.channels] {
+			mono += float64(sample)
+		}
+		onset := s.detect(mono*scale, threshold)
+
+		desired := 1.0
+		if enabled {
+			if onset {
+				if s.holding == 0 {
+					s.clicks.Add(1)
+				}
+				s.holding = s.hold + s.lookahead
+			}
+			if s.holding > 0 {
+				s.holding--
+				desired = s.depth
+			}
+		} else {
+			s.holding = 0
+		}
+
+		coef := s.releaseCoef
+		if desired < s.gain {
+			coef = s.attackCoef
+		}
+		s.gain = desired + (s.gain-desired)*coef
+
+		delayed := s.delay[s.delayPos*s.channels:]
+		for c := 0; c < s.channels; c++ {
+			out := delayed[c] * float32(s.gain)
+			delayed[c] = frame[i+c]
+			frame[i+c] = out
+		}
+		s.delayPos = (s.delayPos + 1) % s.lookahead
+	}
+	return nil
+}
+
+// detect feeds one mono sample to the detector and reports whether its high
+// frequencies just jumped threshold dB above the background
+func (s *ClickSuppressor) detect(x, threshold float64) bool {
+	c := s.highpass
+	y := c.B0*x + s.hpState[0]
+	s.hpState[0] = c.B1*x - c.A1*y + s.hpState[1]
+	s.hpState[1] = c.B2*x - c.A2*y
+
+	s.fast = y*y + (s.fast-y*y)*s.fastCoef
+	level := 10 * math.Log10(s.fast+1e-12)
+
+	// Tracking the background in dB keeps a click from dragging it up much
+	if s.settled < s.settle {
+		s.settled++
+		s.background = level + (s.background-level)*s.settleCoef
+		return false
+	}
+	onset := level > clickFloorDB && level-s.background > threshold
+	s.background = level + (s.background-level)*s.backgroundCoef
+	return onset
+}
+
+// thresholdDB returns how far an onset must rise above the background at the
+// current sensitivity
+func (s *ClickSuppressor) thresholdDB() float64 {
+	sensitivity := float64(s.Sensitivity())
+	return clickMaxThresholdDB - sensitivity*(clickMaxThresholdDB-clickMinThresholdDB)
+}
+
+// SetSensitivity sets how small a transient is treated as a click, from 0
+// (only the loudest) to 1
+func (s *ClickSuppressor) SetSensitivity(sensitivity float32) {
+	s.sensitivity.Store(math.Float32bits(min(max(0, sensitivity), 1)))
+}
+
+// Sensitivity returns the click detection sensitivity (0-1)
+func (s *ClickSuppressor) Sensitivity() float32 {
+	return math.Float32frombits(s.sensitivity.Load())
+}
+
+// Clicks returns how many clicks have been suppressed since the suppressor
+// was created
+func (s *ClickSuppressor) Clicks() uint64 {
+	return s.clicks.Load()
+}
+
+// SetEnabled turns click suppression on or off; the audio stays delayed
+// either way
+func (s *ClickSuppressor) SetEnabled(enabled bool) {
+	s.enabled.Store(enabled)
+}
+
+// Enabled reports whether clicks are suppressed
+func (s *ClickSuppressor) Enabled() bool {
+	return s.enabled.Load()
+}
+
+// Reset clears the delay line and forgets the background level
+func (s *ClickSuppressor) Reset() {
+	clear(s.delay)
+	s.delayPos = 0
+	s.hpState = [2]float64{}
+	s.fast = 0
+	s.background = clickFloorDB
+	s.settled = 0
+	s.holding = 0
+	s.gain = 1
+}
+
+// Latency returns the lookahead delay in samples per channel
+func (s *ClickSuppressor) Latency() int {
+	return s.lookahead
+}
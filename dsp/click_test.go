@@ -0,0 +1,164 @@
+package dsp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// clickEvery is the spacing of the synthetic key presses
+const clickEvery = testRate / 5
+
+// keyClicks returns quiet room noise with a burst of decaying broadband noise
+// every clickEvery samples, like a mechanical keyboard
+func keyClicks(seed int64, samples int, amplitude float64) []float32 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float32, samples)
+	for i := range out {
+		out[i] = float32(0.002 * rng.NormFloat64())
+		if t := i%clickEvery - clickEvery/2; t >= 0 && t < testRate/100 {
+			decay := math.Exp(-float64(t) / (0.0015 * testRate))
+			out[i] += float32(amplitude * decay * rng.NormFloat64())
+		}
+	}
+	return out
+}
+
+// plosive returns a low-passed noise burst with a sharp onset, like a "p"
+func plosive(seed int64, samples, at int) []float32 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float32, samples)
+	var lowpass float64
+	for i := at; i < min(samples, at+testRate/20); i++ {
+		lowpass = 0.6*lowpass + 0.4*rng.NormFloat64()
+		decay := math.Exp(-float64(i-at) / (0.01 * testRate))
+		out[i] = float32(2 * decay * lowpass)
+	}
+	return out
+}
+
+// clickWindows sums the energy of signal within and between the clicks of a
+// keyClicks signal, skipping the settling time
+func clickWindows(signal []float32, delay int) (clicks, between float64) {
+	for start := clickEvery; start+2*clickEvery <= len(signal); start += clickEvery {
+		onset := start + clickEvery/2 + delay
+		clicks += energy(signal, onset, onset+testRate/100)
+		between += energy(signal, start+delay, onset-testRate/100)
+	}
+	return clicks, between
+}
+
+func TestClickSuppressorRemovesClicks(t *testing.T) {
+	input := keyClicks(1, 2*testRate, 0.3)
+	s := NewClickSuppressor(testRate, 1, DefaultClickConfig(), fixedVoice(0))
+	output := append([]float32(nil), input...)
+	processInFrames(t, s, output)
+
+	inClicks, inBetween := clickWindows(input, 0)
+	outClicks, outBetween := clickWindows(output, s.Latency())
+	if reduction := 10 * math.Log10(inClicks/outClicks); reduction < 15 {
+		t.Errorf("clicks reduced by %.1f dB, want at least 15 dB", reduction)
+	}
+	if change := 10 * math.Log10(outBetween/inBetween); math.Abs(change) > 0.5 {
+		t.Errorf("background between clicks changed by %+.2f dB, want within 0.5 dB", change)
+	}
+	if got, want := s.Clicks(), uint64(2*testRate/clickEvery); got != want {
+		t.Errorf("Clicks() = %d, want %d", got, want)
+	}
+}
+
+func TestClickSuppressorKeepsSpeech(t *testing.T) {
+	samples := testRate
+	speech := speechLike(2, samples, 0.3, 2.5, 0)
+	burst := plosive(3, samples, 2*samples/5) // Between syllables, where plosives start
+	for i := range speech {
+		speech[i] += burst[i]
+	}
+
+	run := func(voice float32) (*ClickSuppressor, []float32) {
+		s := NewClickSuppressor(testRate, 1, DefaultClickConfig(), fixedVoice(voice))
+		output := append([]float32(nil), speech...)
+		processInFrames(t, s, output)
+		return s, output
+	}
+
+	s, output := run(0.9)
+	delay := s.Latency()
+	for i := delay; i < len(output); i++ {
+		if output[i] != speech[i-delay] {
+			t.Fatalf("sample %d = %v while speaking, want the delayed input %v", i, output[i], speech[i-delay])
+		}
+	}
+
+	// Without the voice guard the same plosive would be taken for a click
+	if s, _ := run(0); s.Clicks() == 0 {
+		t.Error("plosive was not detected without speech, so the voice guard is untested")
+	}
+}
+
+func TestClickSuppressorSensitivity(t *testing.T) {
+	input := keyClicks(4, testRate, 0.02)
+	tests := []struct {
+		sensitivity float32
+		suppressed  bool
+	}{
+		{0, false},
+		{1, true},
+	}
+
+	for _, tt := range tests {
+		s := NewClickSuppressor(testRate, 1, DefaultClickConfig(), nil)
+		s.SetSensitivity(tt.sensitivity)
+		output := append([]float32(nil), input...)
+		processInFrames(t, s, output)
+
+		inClicks, _ := clickWindows(input, 0)
+		outClicks, _ := clickWindows(output, s.Latency())
+		reduction := 10 * math.Log10(inClicks/outClicks)
+		if tt.suppressed && reduction < 10 {
+			t.Errorf("sensitivity %v: quiet clicks reduced by %.1f dB, want at least 10 dB", tt.sensitivity, reduction)
+		}
+		if !tt.suppressed && s.Clicks() != 0 {
+			t.Errorf("sensitivity %v: %d quiet clicks suppressed, want none", tt.sensitivity, s.Clicks())
+		}
+	}
+}
+
+func TestClickSuppressorDisabled(t *testing.T) {
+	input := keyClicks(5, testRate, 0.3)
+	s := NewClickSuppressor(testRate, 2, DefaultClickConfig(), nil)
+	s.SetEnabled(false)
+
+	stereo := make([]float32, 2*len(input))
+	for i, sample := range input {
+		stereo[2*i], stereo[2*i+1] = sample, -sample
+	}
+	want := append([]float32(nil), stereo...)
+	for start := 0; start < len(stereo); start += 960 {
+		if err := s.Process(stereo[start : start+960]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	delay := 2 * s.Latency()
+	for i := delay; i < len(stereo); i++ {
+		if stereo[i] != want[i-delay] {
+			t.Fatalf("sample %d = %v while disabled, want the delayed input %v", i, stereo[i], want[i-delay])
+		}
+	}
+	if s.Clicks() != 0 {
+		t.Errorf("Clicks() = %d while disabled, want 0", s.Clicks())
+	}
+}
+
+func TestClickSuppressorDoesNotAllocate(t *testing.T) {
+	s := NewClickSuppressor(testRate, 2, DefaultClickConfig(), fixedVoice(0))
+	frame := make([]float32, 960)
+	clicks := keyClicks(6, 480, 0.3)
+	for i, sample := range clicks {
+		frame[2*i], frame[2*i+1] = sample, sample
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _ = s.Process(frame) }); allocs != 0 {
+		t.Errorf("ClickSuppressor allocates %.1f times per frame, want 0", allocs)
+	}
+}
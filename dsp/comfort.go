@@ -0,0 +1,289 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// noiseFloorRise is how fast the background estimate may climb, in dB
+	// per second; it drops immediately to any quieter frame, so speech
+	// can't hold it up
+	noiseFloorRise = 3.0
+	// noiseFloorMargin is how close to the background a frame's power must
+	// be for it to update the spectral tilt
+	noiseFloorMargin = 2.0
+	// tiltSmoothing is the weight of the previous correlation estimate
+	tiltSmoothing = 0.9
+	// maxTilt keeps the comfort noise shaping filter stable
+	maxTilt = 0.98
+)
+
+// NoiseEstimator is a pass-through Processor that tracks the level and
+// spectral tilt of the background noise it sees, for a ComfortNoise stage
+// to imitate. Place it before the denoiser, where the noise is still there.
+//
+// The level follows the quietest recent frames, and the tilt is the lag-1
+// correlation of the background: positive for rumble and fans, negative for
+// hiss.
+type NoiseEstimator struct {
+	channels int
+	rise     float64 // power factor per sample
+
+	level atomic.Uint32 // float32 bits, background RMS
+	tilt  atomic.Uint32 // float32 bits, lag-1 correlation
+
+	floor  float64 // background power, 0 until the first frame
+	r0, r1 float64 // smoothed background autocorrelation
+	last   float64 // last mono sample of the previous frame
+}
+
+var _ Processor = (*NoiseEstimator)(nil)
+
+// NewNoiseEstimator creates a NoiseEstimator for interleaved audio with the
+// given sample rate and channel count
+func NewNoiseEstimator(sampleRate, channels int) *NoiseEstimator {
+	e := &NoiseEstimator{
+		channels: max(1, channels),
+		rise:     math.Pow(10, noiseFloorRise/10/float64(sampleRate)),
+	}
+	e.Reset()
+	return e
+}
+
+// SetChannels changes the number of interleaved channels per frame. Must not
+// be called while Process is running.
+func (e *NoiseEstimator) SetChannels(channels int) {
+	e.channels = max(1, channels)
+	e.Reset()
+}
+
+// Process measures one interleaved frame, leaving it unchanged
+func (e *NoiseEstimator) Process(frame []float32) error {
+	if len(frame)%e.channels != 0 {
+		return fmt.Errorf("noise estimator expects a multiple of %d samples, got %d", e.channels, len(frame))
+	}
+	samples := len(frame) / e.channels
+	if samples == 0 {
+		return nil
+	}
+
+	scale := 1 / float64(e.channels)
+	var r0, r1 float64
+	previous := e.last
+	for i := 0; i < len(frame); i += e.channels {
+		var mono float64
+		for _, sample := range frame[i : i+e.channels] {
+			mono += float64(sample)
+		}
+		mono *= scale
+		r0 += mono * mono
+		r1 += mono * previous
+		previous = mono
+	}
+	e.last = previous
+	power := r0 / float64(samples)
+
+	if e.floor == 0 || power < e.floor {
+		e.floor = power
+	} else {
+		e.floor *= math.Pow(e.rise, float64(samples))
+	}
+
+	// Only frames that are background noise shape the tilt
+	if power > 0 && power < e.floor*math.Pow(10, noiseFloorMargin/10) {
+		e.r0 = r0 + (e.r0-r0)*tiltSmoothing
+		e.r1 = r1 + (e.r1-r1)*tiltSmoothing
+	}
+
+	tilt := 0.0
+	if e.r0 > 0 {
+		tilt = max(-maxTilt, min(maxTilt, e.r1/e.r0))
+	}
+	e.level.Store(math.Float32bits(float32(math.Sqrt(e.floor))))
+	e.tilt.Store(math.Float32bits(float32(tilt)))
+	return nil
+}
+
+// Level returns the estimated background noise RMS
+func (e *NoiseEstimator) Level() float32 {
+	return math.Float32frombits(e.level.Load())
+}
+
+// Tilt returns the lag-1 correlation of the background noise, from -1
+// (hiss) through 0 (white) to 1 (rumble)
+func (e *NoiseEstimator) Tilt() float32 {
+	return math.Float32frombits(e.tilt.Load())
+}
+
+// Reset forgets the background estimate
+func (e *NoiseEstimator) Reset() {
+	e.floor, e.r0, e.r1, e.last = 0, 0, 0, 0
+	e.level.Store(0)
+	e.tilt.Store(0)
+}
+
+// Latency returns 0: the audio passes through untouched
+func (e *NoiseEstimator) Latency() int {
+	return 0
+}
+
+// ComfortNoiseConfig configures a ComfortNoise stage
+type ComfortNoiseConfig struct {
+	// LevelDB is the comfort noise level relative to the estimated
+	// background noise
+	LevelDB float32
+	// Fade is how long the noise takes to fade in and out as speech stops
+	// and starts
+	Fade time.Duration
+	// VoiceThreshold is the voice probability at and above which no comfort
+	// noise is added
+	VoiceThreshold float32
+}
+
+// DefaultComfortNoiseConfig returns settings that keep a call from sounding
+// dead without bringing the room back
+func DefaultComfortNoiseConfig() ComfortNoiseConfig {
+	return ComfortNoiseConfig{
+		LevelDB:        -10,
+		Fade:           20 * time.Millisecond,
+		VoiceThreshold: 0.6,
+	}
+}
+
+// ComfortNoise is a Processor that adds soft noise matching the level and
+// tilt measured by a NoiseEstimator to frames the VoiceSource classifies as
+// non-speech, so fully suppressed pauses don't sound like a dropped call.
+// Nothing is added while the voice probability is unavailable, e.g. while
+// the denoiser is bypassed and the real noise is still there.
+type ComfortNoise struct {
+	channels       int
+	fadeStep       float32
+	voiceThreshold float32
+	estimator      *NoiseEstimator
+	voice          VoiceSource
+
+	enabled atomic.Bool
+	gain    atomic.Uint32 // float32 bits, linear level relative to the background
+
+	mix   float32 // 0 = no comfort noise, 1 = full level
+	seed  uint32
+	state []float32 // shaping filter state per channel
+}
+
+var _ Processor = (*ComfortNoise)(nil)
+
+const (
+	// comfortSeed starts the noise generator; any non-zero value works
+	comfortSeed = 0x9e3779b9
+	// sqrt3 scales uniform noise in [-1, 1) to unit variance
+	sqrt3 = 1.7320508075688772
+)
+
+// NewComfortNoise creates an enabled ComfortNoise stage imitating the noise
+// measured by estimator, for interleaved audio with the given sample rate
+// and channel count
+func NewComfortNoise(sampleRate, channels int, cfg ComfortNoiseConfig, estimator *NoiseEstimator, voice VoiceSource) *ComfortNoise {
+	c := &ComfortNoise{
+		fadeStep:       1,
+		voiceThreshold: cfg.VoiceThreshold,
+		estimator:      estimator,
+		voice:          voice,
+	}
+	if samples := cfg.Fade.Seconds() * float64(sampleRate); samples > 1 {
+		c.fadeStep = float32(1 / samples)
+	}
+	c.SetLevelDB(cfg.LevelDB)
+	c.enabled.Store(true)
+	c.SetChannels(channels)
+	return c
+}
+
+// SetChannels changes the number of interleaved channels per frame. Must not
+// be called while Process is running.
+func (c *ComfortNoise) SetChannels(channels int) {
+	c.channels = max(1, channels)
+	c.state = make([]float32, c.channels)
+	c.Reset()
+}
+
+// Process adds comfort noise to one interleaved frame in place if it isn't
+// speech
+func (c *ComfortNoise) Process(frame []float32) error {
+	if len(frame)%c.channels != 0 {
+		return fmt.Errorf("comfort noise expects a multiple of %d samples, got %d", c.channels, len(frame))
+	}
+
+	target := float32(0)
+	if c.enabled.Load() && c.voice != nil {
+		if probability, ok := c.voice.VoiceProbability(); ok && probability < c.voiceThreshold {
+			target = 1
+		}
+	}
+	if target == 0 && c.mix == 0 {
+		return nil
+	}
+
+	// Each channel gets independent noise through a one-pole filter whose
+	// lag-1 correlation is the measured tilt, at unit variance
+	tilt := c.estimator.Tilt()
+	amplitude := c.estimator.Level() * math.Float32frombits(c.gain.Load())
+	drive := float32(math.Sqrt(float64(1-tilt*tilt))) * amplitude
+	for i := 0; i < len(frame); i += c.channels {
+		if c.mix < target {
+			c.mix = min(target, c.mix+c.fadeStep)
+		} else {
+			c.mix = max(target, c.mix-c.fadeStep)
+		}
+		for ch := 0; ch < c.channels; ch++ {
+			c.state[ch] = tilt*c.state[ch] + drive*c.white()
+			frame[i+ch] += c.mix * c.state[ch]
+		}
+	}
+	return nil
+}
+
+// white returns uniform noise with unit variance
+func (c *ComfortNoise) white() float32 {
+	// xorshift32
+	c.seed ^= c.seed << 13
+	c.seed ^= c.seed >> 17
+	c.seed ^= c.seed << 5
+	return (float32(c.seed)/float32(math.MaxUint32)*2 - 1) * sqrt3
+}
+
+// SetLevelDB sets the comfort noise level relative to the estimated
+// background noise
+func (c *ComfortNoise) SetLevelDB(db float32) {
+	c.gain.Store(math.Float32bits(dbToLinear(db)))
+}
+
+// LevelDB returns the comfort noise level relative to the background
+func (c *ComfortNoise) LevelDB() float32 {
+	return linearToDB(math.Float32frombits(c.gain.Load()))
+}
+
+// SetEnabled turns comfort noise on or off; it fades out rather than
+// stopping abruptly
+func (c *ComfortNoise) SetEnabled(enabled bool) {
+	c.enabled.Store(enabled)
+}
+
+// Enabled reports whether comfort noise is added
+func (c *ComfortNoise) Enabled() bool {
+	return c.enabled.Load()
+}
+
+// Reset silences the generator and restarts its noise sequence
+func (c *ComfortNoise) Reset() {
+	c.mix = 0
+	c.seed = comfortSeed
+	clear(c.state)
+}
+
+// Latency returns 0: noise is added without delaying the audio
+func (c *ComfortNoise) Latency() int {
+	return 0
+}
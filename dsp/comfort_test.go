@@ -0,0 +1,194 @@
+package dsp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// colouredNoise returns noise with the given RMS whose lag-1 correlation is
+// tilt
+func colouredNoise(seed int64, samples int, amplitude, tilt float64) []float32 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float32, samples)
+	var state float64
+	for i := range out {
+		state = tilt*state + math.Sqrt(1-tilt*tilt)*rng.NormFloat64()
+		out[i] = float32(amplitude * state)
+	}
+	return out
+}
+
+// correlation returns the lag-1 correlation of x
+func correlation(x []float32) float64 {
+	var r0, r1 float64
+	for i := 1; i < len(x); i++ {
+		r0 += float64(x[i]) * float64(x[i])
+		r1 += float64(x[i]) * float64(x[i-1])
+	}
+	return r1 / r0
+}
+
+func TestNoiseEstimatorTracksBackground(t *testing.T) {
+	tests := []struct {
+		name   string
+		tilt   float64
+		speech bool
+	}{
+		{"Rumble", 0.8, false},
+		{"Hiss", -0.5, false},
+		{"White", 0, false},
+		{"RumbleWithSpeech", 0.8, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := colouredNoise(1, 3*testRate, 0.01, tt.tilt)
+			if tt.speech {
+				// Phrases of 600ms with pauses in between
+				speech := speechLike(2, len(input), 0.3, 2.5, 0)
+				for i := range input {
+					if i%testRate < 3*testRate/5 {
+						input[i] += speech[i]
+					}
+				}
+			}
+			want := append([]float32(nil), input...)
+
+			e := NewNoiseEstimator(testRate, 1)
+			processInFrames(t, e, input)
+			for i := range input {
+				if input[i] != want[i] {
+					t.Fatalf("sample %d = %v, want %v unchanged", i, input[i], want[i])
+				}
+			}
+
+			if change := toDB(float64(e.Level()) / 0.01); math.Abs(change) > 1.5 {
+				t.Errorf("Level() is %+.1f dB from the background, want within 1.5 dB", change)
+			}
+			if got := float64(e.Tilt()); math.Abs(got-tt.tilt) > 0.05 {
+				t.Errorf("Tilt() = %.2f, want %.2f", got, tt.tilt)
+			}
+		})
+	}
+}
+
+func TestComfortNoiseFillsSilence(t *testing.T) {
+	const tilt = 0.8
+	e := NewNoiseEstimator(testRate, 2)
+	stereo := make([]float32, 2*testRate)
+	mono := colouredNoise(3, testRate, 0.01, tilt)
+	for i, sample := range mono {
+		stereo[2*i], stereo[2*i+1] = sample, sample
+	}
+	processInFrames(t, e, stereo)
+
+	tests := []struct {
+		name    string
+		voice   VoiceSource
+		levelDB float32
+		want    float64 // RMS, 0 for untouched silence
+	}{
+		{"Pause", fixedVoice(0), -10, 0.01 * math.Pow(10, -10.0/20)},
+		{"Louder", fixedVoice(0), 0, 0.01},
+		{"Speech", fixedVoice(0.9), -10, 0},
+		{"NoVoiceSource", nil, -10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultComfortNoiseConfig()
+			cfg.LevelDB = tt.levelDB
+			c := NewComfortNoise(testRate, 2, cfg, e, tt.voice)
+
+			output := make([]float32, 2*testRate)
+			processInFrames(t, c, output)
+
+			if tt.want == 0 {
+				for i, sample := range output {
+					if sample != 0 {
+						t.Fatalf("sample %d = %v, want silence", i, sample)
+					}
+				}
+				return
+			}
+
+			left := make([]float32, testRate/2)
+			right := make([]float32, testRate/2)
+			for i := range left {
+				left[i], right[i] = output[testRate+2*i], output[testRate+2*i+1]
+			}
+			for ch, x := range [][]float32{left, right} {
+				if change := toDB(rms(x) / tt.want); math.Abs(change) > 1.5 {
+					t.Errorf("channel %d level is %+.1f dB from the target, want within 1.5 dB", ch, change)
+				}
+				if got := correlation(x); math.Abs(got-tilt) > 0.05 {
+					t.Errorf("channel %d tilt = %.2f, want %.2f", ch, got, tilt)
+				}
+			}
+
+			// Room noise isn't mono
+			var cross float64
+			for i := range left {
+				cross += float64(left[i]) * float64(right[i])
+			}
+			if r := cross / float64(len(left)) / (rms(left) * rms(right)); math.Abs(r) > 0.2 {
+				t.Errorf("channels correlate at %.2f, want independent noise", r)
+			}
+		})
+	}
+}
+
+func TestComfortNoiseFades(t *testing.T) {
+	e := NewNoiseEstimator(testRate, 1)
+	processInFrames(t, e, colouredNoise(4, testRate, 0.01, 0))
+
+	voice := fixedVoice(0)
+	c := NewComfortNoise(testRate, 1, DefaultComfortNoiseConfig(), e, &voice)
+	frame := make([]float32, 480)
+
+	// The first frame fades in over the configured 20ms rather than jumping
+	if err := c.Process(frame); err != nil {
+		t.Fatal(err)
+	}
+	if start, end := rms(frame[:48]), rms(frame[432:]); start > end/4 {
+		t.Errorf("noise started at %.5f RMS and reached %.5f, want a fade in", start, end)
+	}
+
+	// Once speech starts it fades out within the fade time and stays off
+	voice = 0.9
+	for f := 0; f < 3; f++ {
+		clear(frame)
+		if err := c.Process(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, sample := range frame {
+		if sample != 0 {
+			t.Fatalf("sample %d = %v after speech started, want silence", i, sample)
+		}
+	}
+
+	// Disabling fades out too
+	voice = 0
+	c.SetEnabled(false)
+	for f := 0; f < 3; f++ {
+		clear(frame)
+		if err := c.Process(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rms(frame) != 0 {
+		t.Error("comfort noise added while disabled")
+	}
+}
+
+func TestComfortNoiseDoesNotAllocate(t *testing.T) {
+	e := NewNoiseEstimator(testRate, 2)
+	c := NewComfortNoise(testRate, 2, DefaultComfortNoiseConfig(), e, fixedVoice(0))
+	frame := speechLike(1, 960, 0.01, 2.5, 0)
+	chain := NewChain(e, c)
+	if allocs := testing.AllocsPerRun(100, func() { _ = chain.Process(frame) }); allocs != 0 {
+		t.Errorf("NoiseEstimator and ComfortNoise allocate %.1f times per frame, want 0", allocs)
+	}
+}
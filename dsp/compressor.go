@@ -0,0 +1,211 @@
+package dsp
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// CompressorConfig configures the dynamics compressor
+type CompressorConfig struct {
+	// ThresholdDB is the level in dBFS above which gain is reduced
+	ThresholdDB float32
+	// Ratio is how many dB the input must rise for the output to rise 1 dB
+	Ratio float32
+	// KneeDB is the width of the soft knee around the threshold (0 = hard knee)
+	KneeDB float32
+	// Attack is how quickly gain reduction is applied
+	Attack time.Duration
+	// Release is how quickly gain reduction is let go
+	Release time.Duration
+	// MakeupDB is gain added after compression
+	MakeupDB float32
+}
+
+// DefaultCompressorConfig returns gentle settings for speech
+func DefaultCompressorConfig() CompressorConfig {
+	return CompressorConfig{
+		ThresholdDB: -20,
+		Ratio:       3,
+		KneeDB:      6,
+		Attack:      5 * time.Millisecond,
+		Release:     120 * time.Millisecond,
+		MakeupDB:    3,
+	}
+}
+
+// compressorUnity is the gain in dB below which a disabled compressor is
+// treated as having settled back at unity
+const compressorUnity = 1e-4
+
+// Compressor is a feed-forward Processor that reduces the dynamic range of
+// speech. The detector follows the loudest channel so the stereo image
+// doesn't shift.
+type Compressor struct {
+	channels    int
+	attackCoef  float64
+	releaseCoef float64
+
+	enabled   atomic.Bool
+	threshold atomic.Uint32 // float32 bits, dBFS
+	ratio     atomic.Uint32 // float32 bits
+	knee      atomic.Uint32 // float32 bits, dB
+	makeup    atomic.Uint32 // float32 bits, dB
+	meterBits atomic.Uint32 // float32 bits, peak gain reduction of the last frame in dB
+
+	reduction float64 // smoothed gain reduction in dB
+	makeupDB  float64 // smoothed makeup gain in dB
+	settled   bool    // false until the first frame after a reset
+}
+
+var _ Processor = (*Compressor)(nil)
+
+// NewCompressor creates an enabled compressor for interleaved audio with
+// the given sample rate and channel count
+func NewCompressor(sampleRate, channels int, cfg CompressorConfig) *Compressor {
+	c := &Compressor{
+		channels:    max(1, channels),
+		attackCoef:  smoothingCoef(cfg.Attack, sampleRate),
+		releaseCoef: smoothingCoef(cfg.Release, sampleRate),
+	}
+	c.SetThresholdDB(cfg.ThresholdDB)
+	c.SetRatio(cfg.Ratio)
+	c.SetKneeDB(cfg.KneeDB)
+	c.SetMakeupDB(cfg.MakeupDB)
+	c.enabled.Store(true)
+	c.Reset()
+	return c
+}
+
+// gainReduction returns the static gain reduction in dB for an input level,
+// with a quadratic soft knee
+func gainReduction(levelDB, thresholdDB, ratio, kneeDB float64) float64 {
+	over := levelDB - thresholdDB
+	slope := 1 - 1/ratio
+	switch {
+	case 2*over <= -kneeDB:
+		return 0
+	case 2*math.Abs(over) < kneeDB:
+		return slope * (over + kneeDB/2) * (over + kneeDB/2) / (2 * kneeDB)
+	default:
+		return slope * over
+	}
+}
+
+// Process compresses one interleaved frame in place
+func (c *Compressor) Process(frame []float32) error {
+	enabled := c.enabled.Load()
+	threshold := float64(math.Float32frombits(c.threshold.Load()))
+	ratio := float64(math.Float32frombits(c.ratio.Load()))
+	knee := float64(math.Float32frombits(c.knee.Load()))
+	makeup := float64(math.Float32frombits(c.makeup.Load()))
+	if !enabled {
+		makeup = 0 // Glide back to unity instead of jumping
+	}
+	if !c.settled {
+		// Nothing has been heard yet, so start at the makeup gain the
+		// compressor is enabled with now rather than gliding to it
+		c.makeupDB = makeup
+		c.settled = true
+	}
+	if !enabled && c.reduction < compressorUnity && math.Abs(c.makeupDB) < compressorUnity {
+		c.reduction, c.makeupDB = 0, 0
+		c.meterBits.Store(0)
+		return nil
+	}
+
+	var meter float64
+	for i := 0; i < len(frame); i += c.channels {
+		end := min(i+c.channels, len(frame))
+
+		var target float64
+		if enabled {
+			var peak float32
+			for _, sample := range frame[i:end] {
+				peak = max(peak, abs32(sample))
+			}
+			if peak > 0 {
+				level := 20 * math.Log10(float64(peak))
+				target = gainReduction(level, threshold, ratio, knee)
+			}
+		}
+
+		coef := c.releaseCoef
+		if target > c.reduction {
+			coef = c.attackCoef
+		}
+		c.reduction = target + (c.reduction-target)*coef
+		c.makeupDB = makeup + (c.makeupDB-makeup)*c.releaseCoef
+		meter = max(meter, c.reduction)
+
+		gain := float32(math.Pow(10, (c.makeupDB-c.reduction)/20))
+		for j := i; j < end; j++ {
+			frame[j] *= gain
+		}
+	}
+
+	c.meterBits.Store(math.Float32bits(float32(meter)))
+	return nil
+}
+
+// SetChannels changes the number of interleaved channels per frame. Must not
+// be called while Process is running.
+func (c *Compressor) SetChannels(channels int) {
+	c.channels = max(1, channels)
+}
+
+// Reset releases any gain reduction; the makeup gain jumps to its setting at
+// the next frame
+func (c *Compressor) Reset() {
+	c.reduction = 0
+	c.makeupDB = 0
+	c.settled = false
+	c.meterBits.Store(0)
+}
+
+// Latency returns 0: the compressor has no lookahead
+func (c *Compressor) Latency() int {
+	return 0
+}
+
+// SetEnabled turns the compressor on or off; when off the gain glides back
+// to unity
+func (c *Compressor) SetEnabled(enabled bool) {
+	c.enabled.Store(enabled)
+}
+
+// Enabled reports whether the compressor is active
+func (c *Compressor) Enabled() bool {
+	return c.enabled.Load()
+}
+
+// SetThresholdDB sets the compression threshold in dBFS
+func (c *Compressor) SetThresholdDB(db float32) {
+	c.threshold.Store(math.Float32bits(db))
+}
+
+// SetRatio sets the compression ratio (values below 1 are treated as 1)
+func (c *Compressor) SetRatio(ratio float32) {
+	c.ratio.Store(math.Float32bits(max(1, ratio)))
+}
+
+// SetKneeDB sets the soft knee width in dB (negative values are treated as 0)
+func (c *Compressor) SetKneeDB(db float32) {
+	c.knee.Store(math.Float32bits(max(0, db)))
+}
+
+// SetMakeupDB sets the gain added after compression
+func (c *Compressor) SetMakeupDB(db float32) {
+	c.makeup.Store(math.Float32bits(db))
+}
+
+// GainReductionDB returns the largest gain reduction applied during the last
+// frame, in dB (0 when not compressing)
+func (c *Compressor) GainReductionDB() float32 {
+	return math.Float32frombits(c.meterBits.Load())
+}
+
+// abs32 returns the absolute value of a sample
+func abs32(x float32) float32 {
+	return math.Float32frombits(math.Float32bits(x) &^ (1 << 31))
+}
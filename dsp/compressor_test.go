@@ -0,0 +1,156 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGainReductionCurve(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     float64
+		knee      float64
+		reduction float64
+	}{
+		{"BelowThreshold", -30, 0, 0},
+		{"AtThresholdHardKnee", -20, 0, 0},
+		{"AboveThreshold", -8, 0, 8},
+		{"BelowKnee", -24, 6, 0},
+		{"KneeCentre", -20, 6, 0.5},
+		{"AboveKnee", -8, 6, 8},
+	}
+
+	// 3:1 above -20 dBFS: every dB over the threshold loses 2/3 dB
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gainReduction(tt.level, -20, 3, tt.knee)
+			if math.Abs(got-tt.reduction) > 1e-9 {
+				t.Errorf("gainReduction(%g dB) = %g dB, want %g dB", tt.level, got, tt.reduction)
+			}
+		})
+	}
+
+	t.Run("KneeIsContinuous", func(t *testing.T) {
+		for _, edge := range []float64{-23, -17} {
+			below := gainReduction(edge-1e-6, -20, 3, 6)
+			above := gainReduction(edge+1e-6, -20, 3, 6)
+			if math.Abs(below-above) > 1e-4 {
+				t.Errorf("reduction jumps from %g to %g dB at %g dBFS", below, above, edge)
+			}
+		}
+	})
+}
+
+// settle runs a constant-level signal through p and returns the last frame
+func settle(p Processor, level float32, frames int) []float32 {
+	frame := make([]float32, 480)
+	for f := 0; f < frames; f++ {
+		for i := range frame {
+			frame[i] = level
+		}
+		_ = p.Process(frame)
+	}
+	return frame
+}
+
+func TestCompressorSteadyState(t *testing.T) {
+	cfg := CompressorConfig{
+		ThresholdDB: -20,
+		Ratio:       4,
+		Attack:      time.Millisecond,
+		Release:     50 * time.Millisecond,
+		MakeupDB:    2,
+	}
+
+	t.Run("Loud", func(t *testing.T) {
+		c := NewCompressor(testRate, 1, cfg)
+		frame := settle(c, 0.5, 50)
+
+		// -6 dBFS is 14 dB over: reduced by 10.5 dB, then 2 dB makeup
+		in := 20 * math.Log10(0.5)
+		want := in - 0.75*(in+20) + 2
+		if got := 20 * math.Log10(float64(frame[len(frame)-1])); math.Abs(got-want) > 0.05 {
+			t.Errorf("output level = %.2f dBFS, want %.2f dBFS", got, want)
+		}
+		if gr := float64(c.GainReductionDB()); math.Abs(gr-0.75*(in+20)) > 0.05 {
+			t.Errorf("GainReductionDB() = %.2f, want %.2f", gr, 0.75*(in+20))
+		}
+	})
+
+	t.Run("Quiet", func(t *testing.T) {
+		c := NewCompressor(testRate, 1, cfg)
+		frame := settle(c, 0.01, 50)
+		if got := 20 * math.Log10(float64(frame[0])/0.01); math.Abs(got-2) > 0.01 {
+			t.Errorf("gain below threshold = %.2f dB, want the 2 dB makeup only", got)
+		}
+		if gr := c.GainReductionDB(); gr != 0 {
+			t.Errorf("GainReductionDB() = %.2f below threshold, want 0", gr)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		c := NewCompressor(testRate, 1, cfg)
+		c.SetEnabled(false)
+		frame := settle(c, 0.5, 1)
+		if math.Abs(float64(frame[0])-0.5) > 1e-4 {
+			t.Errorf("disabled compressor's first output = %v, want 0.5", frame[0])
+		}
+		frame = settle(c, 0.5, 50)
+		if frame[0] != 0.5 {
+			t.Errorf("disabled compressor output = %v, want 0.5", frame[0])
+		}
+	})
+
+	t.Run("DisabledSettles", func(t *testing.T) {
+		c := NewCompressor(testRate, 1, cfg)
+		settle(c, 0.5, 50)
+		c.SetEnabled(false)
+
+		// The gain glides back to unity, then stops being applied
+		frame := settle(c, 0.5, 1)
+		if frame[0] == 0.5 {
+			t.Error("gain jumped to unity when the compressor was disabled")
+		}
+		frame = settle(c, 0.5, 200)
+		if frame[0] != 0.5 {
+			t.Errorf("disabled compressor output = %v after settling, want 0.5", frame[0])
+		}
+		if gr := c.GainReductionDB(); gr != 0 {
+			t.Errorf("GainReductionDB() = %.2f while disabled, want 0", gr)
+		}
+	})
+}
+
+func TestCompressorLinksChannels(t *testing.T) {
+	c := NewCompressor(testRate, 2, CompressorConfig{ThresholdDB: -20, Ratio: 4, Attack: time.Millisecond, Release: 50 * time.Millisecond})
+
+	frame := make([]float32, 960)
+	for f := 0; f < 50; f++ {
+		for i := 0; i < len(frame); i += 2 {
+			frame[i], frame[i+1] = 0.5, 0.05 // Loud left, quiet right
+		}
+		_ = c.Process(frame)
+	}
+
+	// The quiet channel is turned down by the same amount as the loud one
+	left := frame[len(frame)-2] / 0.5
+	right := frame[len(frame)-1] / 0.05
+	if math.Abs(float64(left-right)) > 1e-4 {
+		t.Errorf("channel gains differ: left %.4f, right %.4f", left, right)
+	}
+	if left > 0.5 {
+		t.Errorf("gain = %.3f, want the loud channel to be compressed", left)
+	}
+}
+
+func TestCompressorDoesNotAllocate(t *testing.T) {
+	c := NewCompressor(testRate, 2, DefaultCompressorConfig())
+	frame := make([]float32, 960)
+	for i := range frame {
+		frame[i] = 0.3
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _ = c.Process(frame) }); allocs != 0 {
+		t.Errorf("Compressor.Process allocates %.1f times per frame, want 0", allocs)
+	}
+}
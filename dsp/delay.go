@@ -0,0 +1,147 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+const (
+	// delayDecimation downsamples both signals before correlating (to 4 kHz
+	// at 48 kHz); the peak only needs speech's strong low band, and the
+	// short history keeps the FFT at 4096 points on the audio thread
+	delayDecimation = 12
+	// delayConfidence is how many times the correlation peak must exceed the
+	// correlation's RMS to be trusted
+	delayConfidence = 8
+	// delayMinLevel is the RMS below which a signal is treated as silent
+	delayMinLevel = 1e-4
+)
+
+// delayEstimator finds the echo delay between a far-end reference and the
+// microphone with a phase-transform weighted cross-correlation (GCC-PHAT).
+// An estimate is only reported when two in a row agree.
+type delayEstimator struct {
+	window int // microphone history in decimated samples
+	maxLag int // longest delay searched, in decimated samples
+
+	mic, far       []float64 // decimated history rings
+	micPos, farPos int       // oldest sample in each ring
+	micAcc, farAcc float64
+	accCount       int
+
+	fft              *FFT
+	micSpec, farSpec []complex128
+
+	candidate int // last accepted correlation lag, -1 if none
+}
+
+// newDelayEstimator searches delays up to maxDelay samples over a window of
+// microphone history, both at the full sample rate
+func newDelayEstimator(window, maxDelay int) *delayEstimator {
+	d := &delayEstimator{
+		window: window / delayDecimation,
+		maxLag: maxDelay / delayDecimation,
+	}
+	d.mic = make([]float64, d.window)
+	d.far = make([]float64, d.window+d.maxLag)
+
+	// Only lags where the microphone window lies inside the far history are
+	// searched, so the circular correlation never wraps if the FFT covers
+	// the far history alone
+	size := 1
+	for size < len(d.far) {
+		size *= 2
+	}
+	d.fft = NewFFT(size)
+	d.micSpec = make([]complex128, size)
+	d.farSpec = make([]complex128, size)
+	d.reset()
+	return d
+}
+
+// add appends one microphone and one reference sample
+func (d *delayEstimator) add(mic, far float32) {
+	d.micAcc += float64(mic)
+	d.farAcc += float64(far)
+	d.accCount++
+	if d.accCount < delayDecimation {
+		return
+	}
+
+	d.mic[d.micPos] = d.micAcc / delayDecimation
+	d.micPos = (d.micPos + 1) % len(d.mic)
+	d.far[d.farPos] = d.farAcc / delayDecimation
+	d.farPos = (d.farPos + 1) % len(d.far)
+	d.micAcc, d.farAcc, d.accCount = 0, 0, 0
+}
+
+// estimate returns the delay in samples at the full rate, or ok = false if
+// the signals don't give a confident, repeated answer
+func (d *delayEstimator) estimate() (delay int, ok bool) {
+	if rmsOf(d.mic) < delayMinLevel || rmsOf(d.far) < delayMinLevel {
+		return 0, false
+	}
+
+	clear(d.micSpec)
+	clear(d.farSpec)
+	for i := range d.mic {
+		d.micSpec[i] = complex(d.mic[(d.micPos+i)%len(d.mic)], 0)
+	}
+	for i := range d.far {
+		d.farSpec[i] = complex(d.far[(d.farPos+i)%len(d.far)], 0)
+	}
+	d.fft.Forward(d.micSpec)
+	d.fft.Forward(d.farSpec)
+
+	// Whiten the cross-spectrum so the peak is sharp for coloured signals
+	for i := range d.micSpec {
+		cross := cmplx.Conj(d.micSpec[i]) * d.farSpec[i]
+		d.micSpec[i] = cross / complex(cmplx.Abs(cross)+1e-12, 0)
+	}
+	d.fft.Inverse(d.micSpec)
+
+	// Lag k lines the microphone up with the far history starting k samples
+	// in; the echo delay is how far that is behind the newest far audio
+	best, peak, sum := 0, math.Inf(-1), 0.0
+	for k := 0; k <= d.maxLag; k++ {
+		c := real(d.micSpec[k])
+		sum += c * c
+		if c > peak {
+			best, peak = k, c
+		}
+	}
+	if peak < delayConfidence*math.Sqrt(sum/float64(d.maxLag+1)) {
+		return 0, false
+	}
+
+	lag := d.maxLag - best
+	agreed := d.candidate >= 0 && abs(lag-d.candidate) <= 1
+	d.candidate = lag
+	return lag * delayDecimation, agreed
+}
+
+// reset forgets the signal history
+func (d *delayEstimator) reset() {
+	clear(d.mic)
+	clear(d.far)
+	d.micPos, d.farPos = 0, 0
+	d.micAcc, d.farAcc, d.accCount = 0, 0, 0
+	d.candidate = -1
+}
+
+// rmsOf returns the RMS of a signal
+func rmsOf(signal []float64) float64 {
+	var sum float64
+	for _, sample := range signal {
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(len(signal)))
+}
+
+// abs returns the absolute value of an int
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,369 @@
+package dsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// BandType selects the filter shape of an EQ band
+type BandType int
+
+const (
+	PeakingBand BandType = iota
+	LowShelfBand
+	HighShelfBand
+	LowPassBand
+	HighPassBand
+)
+
+var bandTypeNames = map[BandType]string{
+	PeakingBand:   "peaking",
+	LowShelfBand:  "lowshelf",
+	HighShelfBand: "highshelf",
+	LowPassBand:   "lowpass",
+	HighPassBand:  "highpass",
+}
+
+// String returns the band type's name as used in config files
+func (t BandType) String() string {
+	if name, ok := bandTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("BandType(%d)", int(t))
+}
+
+// ParseBandType converts a name such as "peaking" or "highshelf" to a BandType
+func ParseBandType(name string) (BandType, error) {
+	for t, n := range bandTypeNames {
+		if strings.EqualFold(n, name) {
+			return t, nil
+		}
+	}
+	return PeakingBand, fmt.Errorf("unknown EQ band type %q (expected peaking, lowshelf, highshelf, lowpass or highpass)", name)
+}
+
+// MarshalText encodes the band type by name
+func (t BandType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a band type name
+func (t *BandType) UnmarshalText(text []byte) error {
+	parsed, err := ParseBandType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// EQBand is one band of the parametric equalizer
+type EQBand struct {
+	Type      BandType `json:"type"`
+	Frequency float64  `json:"frequency"`
+	Q         float64  `json:"q"`
+	// GainDB is ignored by low-pass and high-pass bands
+	GainDB float64 `json:"gain_db"`
+}
+
+// ParseEQBand parses a band written as type:frequency:q[:gain_db], e.g.
+// "peaking:3000:1:4"
+func ParseEQBand(spec string) (EQBand, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return EQBand{}, fmt.Errorf("invalid EQ band %q (expected type:frequency:q[:gain_db])", spec)
+	}
+
+	bandType, err := ParseBandType(parts[0])
+	if err != nil {
+		return EQBand{}, err
+	}
+	band := EQBand{Type: bandType}
+
+	values := []*float64{&band.Frequency, &band.Q, &band.GainDB}
+	for i, part := range parts[1:] {
+		if *values[i], err = strconv.ParseFloat(part, 64); err != nil {
+			return EQBand{}, fmt.Errorf("invalid EQ band %q: %v", spec, err)
+		}
+	}
+	return band, nil
+}
+
+// Validate checks that the band can be realised at sampleRate
+func (b EQBand) Validate(sampleRate int) error {
+	if b.Frequency <= 0 || b.Frequency >= float64(sampleRate)/2 {
+		return fmt.Errorf("EQ band frequency %g Hz must be between 0 and %d Hz", b.Frequency, sampleRate/2)
+	}
+	if b.Q <= 0 {
+		return fmt.Errorf("EQ band Q %g must be positive", b.Q)
+	}
+	return nil
+}
+
+// Coefficients returns the band's biquad coefficients
+func (b EQBand) Coefficients(sampleRate int) BiquadCoefficients {
+	switch b.Type {
+	case LowShelfBand:
+		return LowShelfCoefficients(sampleRate, b.Frequency, b.Q, b.GainDB)
+	case HighShelfBand:
+		return HighShelfCoefficients(sampleRate, b.Frequency, b.Q, b.GainDB)
+	case LowPassBand:
+		return LowPassCoefficients(sampleRate, b.Frequency, b.Q)
+	case HighPassBand:
+		return HighPassCoefficients(sampleRate, b.Frequency, b.Q)
+	default:
+		return PeakingCoefficients(sampleRate, b.Frequency, b.Q, b.GainDB)
+	}
+}
+
+// eqPresets are the built-in band sets, selectable by name
+var eqPresets = map[string][]EQBand{
+	"flat": nil,
+	// Trims boom, lifts articulation around 3-5 kHz and adds a little air
+	"voice-presence": {
+		{Type: HighPassBand, Frequency: 90, Q: butterworthQ},
+		{Type: LowShelfBand, Frequency: 200, Q: butterworthQ, GainDB: -2},
+		{Type: PeakingBand, Frequency: 3500, Q: 1, GainDB: 4},
+		{Type: HighShelfBand, Frequency: 10000, Q: butterworthQ, GainDB: 2},
+	},
+	// Fills out thin laptop microphones
+	"warm": {
+		{Type: LowShelfBand, Frequency: 180, Q: butterworthQ, GainDB: 3},
+		{Type: PeakingBand, Frequency: 2500, Q: 1.2, GainDB: -1.5},
+		{Type: LowPassBand, Frequency: 14000, Q: butterworthQ},
+	},
+}
+
+// EQPresets returns the names of the built-in presets in alphabetical order
+func EQPresets() []string {
+	names := make([]string, 0, len(eqPresets))
+	for name := range eqPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EQPreset returns the bands of a built-in preset
+func EQPreset(name string) ([]EQBand, error) {
+	bands, ok := eqPresets[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown EQ preset %q (available: %s)", name, strings.Join(EQPresets(), ", "))
+	}
+	return append([]EQBand(nil), bands...), nil
+}
+
+// EQConfig is the JSON config file format for the equalizer
+type EQConfig struct {
+	// Preset, if set, provides bands before those listed in Bands
+	Preset string   `json:"preset,omitempty"`
+	Bands  []EQBand `json:"bands"`
+}
+
+// LoadEQConfig reads an EQ config file and returns its bands
+func LoadEQConfig(path string) ([]EQBand, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EQ config: %v", err)
+	}
+
+	var cfg EQConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid EQ config %s: %v", path, err)
+	}
+
+	var bands []EQBand
+	if cfg.Preset != "" {
+		if bands, err = EQPreset(cfg.Preset); err != nil {
+			return nil, err
+		}
+	}
+	return append(bands, cfg.Bands...), nil
+}
+
+// eqFadeSeconds is how long the EQ crossfades after its bands change
+const eqFadeSeconds = 0.01
+
+// eqCascade is a series of biquads with per-channel state
+type eqCascade struct {
+	bands  []EQBand
+	coeffs []BiquadCoefficients
+	z1, z2 [][]float64 // [band][channel]
+}
+
+// newCascade designs a cascade for bands with zeroed state
+func newCascade(bands []EQBand, sampleRate, channels int) *eqCascade {
+	c := &eqCascade{bands: append([]EQBand(nil), bands...)}
+	for _, band := range bands {
+		c.coeffs = append(c.coeffs, band.Coefficients(sampleRate))
+		c.z1 = append(c.z1, make([]float64, channels))
+		c.z2 = append(c.z2, make([]float64, channels))
+	}
+	return c
+}
+
+// tick filters one sample of channel ch through every band
+func (c *eqCascade) tick(x float64, ch int) float64 {
+	for i := range c.coeffs {
+		k := &c.coeffs[i]
+		y := k.B0*x + c.z1[i][ch]
+		c.z1[i][ch] = k.B1*x - k.A1*y + c.z2[i][ch]
+		c.z2[i][ch] = k.B2*x - k.A2*y
+		x = y
+	}
+	return x
+}
+
+// reset clears the filter state
+func (c *eqCascade) reset() {
+	for i := range c.z1 {
+		clear(c.z1[i])
+		clear(c.z2[i])
+	}
+}
+
+// EQ is a multi-band parametric equalizer Processor. Bands can be changed
+// while audio is running: the new settings are crossfaded in over 10ms so
+// there are no clicks.
+type EQ struct {
+	sampleRate int
+	channels   int
+	fadeFrames int
+
+	pending  atomic.Pointer[eqCascade] // set by SetBands, picked up by Process
+	settings atomic.Pointer[eqCascade] // latest bands, for Bands()
+
+	current  *eqCascade
+	previous *eqCascade // cascade being faded out, nil when not fading
+	fadePos  int
+}
+
+var _ Processor = (*EQ)(nil)
+
+// NewEQ creates an equalizer for interleaved audio with the given bands
+func NewEQ(sampleRate, channels int, bands []EQBand) (*EQ, error) {
+	e := &EQ{
+		sampleRate: sampleRate,
+		channels:   max(1, channels),
+		fadeFrames: int(eqFadeSeconds * float64(sampleRate)),
+	}
+	for _, band := range bands {
+		if err := band.Validate(sampleRate); err != nil {
+			return nil, err
+		}
+	}
+	e.current = newCascade(bands, sampleRate, e.channels)
+	e.settings.Store(e.current)
+	return e, nil
+}
+
+// SetBands replaces all bands; the change is crossfaded in from the next
+// frame. On error the current bands are kept.
+func (e *EQ) SetBands(bands []EQBand) error {
+	for _, band := range bands {
+		if err := band.Validate(e.sampleRate); err != nil {
+			return err
+		}
+	}
+	c := newCascade(bands, e.sampleRate, e.channels)
+	e.settings.Store(c)
+	e.pending.Store(c)
+	return nil
+}
+
+// Bands returns the most recently set bands
+func (e *EQ) Bands() []EQBand {
+	return append([]EQBand(nil), e.settings.Load().bands...)
+}
+
+// Response returns the EQ's combined complex frequency response at freq
+func (e *EQ) Response(freq float64) complex128 {
+	response := complex(1, 0)
+	for _, band := range e.settings.Load().bands {
+		response *= band.Coefficients(e.sampleRate).Response(e.sampleRate, freq)
+	}
+	return response
+}
+
+// MagnitudeDB returns the EQ's combined gain in dB at freq
+func (e *EQ) MagnitudeDB(freq float64) float64 {
+	return 20 * math.Log10(cmplx.Abs(e.Response(freq)))
+}
+
+// SetChannels changes the number of interleaved channels and clears the
+// filter state. Must not be called while Process is running.
+func (e *EQ) SetChannels(channels int) {
+	e.channels = max(1, channels)
+	e.pending.Store(nil)
+	e.current = newCascade(e.settings.Load().bands, e.sampleRate, e.channels)
+	e.settings.Store(e.current)
+	e.previous = nil
+}
+
+// Process equalizes one interleaved frame in place
+func (e *EQ) Process(frame []float32) error {
+	// A change that arrives mid-fade waits for the fade to finish so the
+	// half-faded cascade isn't dropped; only the latest change is kept
+	if next := e.nextCascade(); next != nil {
+		// Start the new cascade from the old state where the band layout
+		// matches, then crossfade from the old cascade's output
+		for i := range next.coeffs {
+			if i < len(e.current.coeffs) {
+				copy(next.z1[i], e.current.z1[i])
+				copy(next.z2[i], e.current.z2[i])
+			}
+		}
+		e.previous = e.current
+		e.current = next
+		e.fadePos = 0
+	}
+
+	for i, sample := range frame {
+		ch := i % e.channels
+		x := float64(sample)
+		y := e.current.tick(x, ch)
+
+		if e.previous != nil {
+			mix := float64(e.fadePos) / float64(e.fadeFrames)
+			y = mix*y + (1-mix)*e.previous.tick(x, ch)
+			if ch == e.channels-1 {
+				e.fadePos++
+				if e.fadePos >= e.fadeFrames {
+					e.previous = nil
+				}
+			}
+		}
+		frame[i] = float32(y)
+	}
+	return nil
+}
+
+// nextCascade returns the cascade set by SetBands since the last change was
+// picked up, or nil if there is none or a crossfade is still running
+func (e *EQ) nextCascade() *eqCascade {
+	if e.previous != nil {
+		return nil
+	}
+	return e.pending.Swap(nil)
+}
+
+// Reset clears the filter state and finishes any crossfade
+func (e *EQ) Reset() {
+	if next := e.pending.Swap(nil); next != nil {
+		e.current = next
+	}
+	e.current.reset()
+	e.previous = nil
+}
+
+// Latency returns 0: the EQ is made of causal IIR filters
+func (e *EQ) Latency() int {
+	return 0
+}
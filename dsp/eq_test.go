@@ -0,0 +1,244 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEQBand(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    EQBand
+		wantErr bool
+	}{
+		{"peaking:3000:1:4", EQBand{Type: PeakingBand, Frequency: 3000, Q: 1, GainDB: 4}, false},
+		{"HighPass:90:0.707", EQBand{Type: HighPassBand, Frequency: 90, Q: 0.707}, false},
+		{"lowshelf:200:0.7:-3", EQBand{Type: LowShelfBand, Frequency: 200, Q: 0.7, GainDB: -3}, false},
+		{"bandpass:1000:1", EQBand{}, true},
+		{"peaking:3000", EQBand{}, true},
+		{"peaking:loud:1:4", EQBand{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseEQBand(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEQBand(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseEQBand(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEQResponseIsProductOfBands(t *testing.T) {
+	bands, err := EQPreset("voice-presence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq, err := NewEQ(testRate, 1, bands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, freq := range []float64{50, 200, 1000, 3500, 12000} {
+		want := complex(1, 0)
+		for _, band := range bands {
+			want *= band.Coefficients(testRate).Response(testRate, freq)
+		}
+		if got := eq.Response(freq); cmplx.Abs(got-want) > 1e-12 {
+			t.Errorf("response at %g Hz = %v, want %v", freq, got, want)
+		}
+	}
+
+	// Presence boost lifts the 3.5 kHz region, the high-pass removes rumble
+	if gain := eq.MagnitudeDB(3500); gain < 3 {
+		t.Errorf("gain at 3.5 kHz = %.2f dB, want a presence boost", gain)
+	}
+	if gain := eq.MagnitudeDB(30); gain > -15 {
+		t.Errorf("gain at 30 Hz = %.2f dB, want rumble cut", gain)
+	}
+}
+
+func TestEQPresets(t *testing.T) {
+	for _, name := range EQPresets() {
+		bands, err := EQPreset(name)
+		if err != nil {
+			t.Fatalf("EQPreset(%q) error = %v", name, err)
+		}
+		if _, err := NewEQ(testRate, 2, bands); err != nil {
+			t.Errorf("preset %q is invalid: %v", name, err)
+		}
+	}
+	if _, err := EQPreset("stadium"); err == nil {
+		t.Error("EQPreset of unknown name succeeded, want error")
+	}
+}
+
+func TestLoadEQConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("PresetAndBands", func(t *testing.T) {
+		path := filepath.Join(dir, "eq.json")
+		config := `{"preset": "voice-presence", "bands": [{"type": "peaking", "frequency": 250, "q": 2, "gain_db": -3}]}`
+		if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		bands, err := LoadEQConfig(path)
+		if err != nil {
+			t.Fatalf("LoadEQConfig() error = %v", err)
+		}
+		preset, _ := EQPreset("voice-presence")
+		if len(bands) != len(preset)+1 {
+			t.Fatalf("got %d bands, want %d", len(bands), len(preset)+1)
+		}
+		if last := bands[len(bands)-1]; last != (EQBand{Type: PeakingBand, Frequency: 250, Q: 2, GainDB: -3}) {
+			t.Errorf("custom band = %+v", last)
+		}
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		path := filepath.Join(dir, "bad.json")
+		if err := os.WriteFile(path, []byte(`{"bands": [{"type": "comb", "frequency": 50, "q": 1}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadEQConfig(path); err == nil {
+			t.Error("LoadEQConfig with an unknown band type succeeded, want error")
+		}
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		if _, err := LoadEQConfig(filepath.Join(dir, "missing.json")); err == nil {
+			t.Error("LoadEQConfig of a missing file succeeded, want error")
+		}
+	})
+}
+
+func TestEQRejectsInvalidBands(t *testing.T) {
+	eq, err := NewEQ(testRate, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, band := range []EQBand{
+		{Type: PeakingBand, Frequency: 30000, Q: 1},
+		{Type: PeakingBand, Frequency: 1000, Q: 0},
+	} {
+		if err := eq.SetBands([]EQBand{band}); err == nil {
+			t.Errorf("SetBands(%+v) succeeded, want error", band)
+		}
+	}
+	if len(eq.Bands()) != 0 {
+		t.Error("failed SetBands replaced the current bands")
+	}
+}
+
+// TestEQChangesAreGlitchFree switches from flat to a large boost mid-sine and
+// checks that the output never steps faster than the louder sine could
+func TestEQChangesAreGlitchFree(t *testing.T) {
+	eq, err := NewEQ(testRate, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const freq, amplitude = 1000.0, 0.2
+	boost := []EQBand{{Type: PeakingBand, Frequency: freq, Q: 1, GainDB: 12}}
+	maxGain := math.Pow(10, 12.0/20)
+	// Largest change between samples of a sine at the boosted level
+	maxStep := amplitude * maxGain * 2 * math.Sin(math.Pi*freq/testRate) * 1.05
+
+	frame := make([]float32, 960)
+	var last [2]float32
+	n := 0
+	for f := 0; f < 40; f++ {
+		if f == 10 {
+			if err := eq.SetBands(boost); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if f == 25 {
+			_ = eq.SetBands(nil)
+		}
+
+		for i := 0; i < len(frame); i += 2 {
+			sample := float32(amplitude * math.Sin(2*math.Pi*freq*float64(n)/testRate))
+			frame[i], frame[i+1] = sample, sample
+			n++
+		}
+		_ = eq.Process(frame)
+
+		for i, sample := range frame {
+			ch := i % 2
+			if f > 0 || i >= 2 {
+				if step := math.Abs(float64(sample - last[ch])); step > maxStep {
+					t.Fatalf("frame %d sample %d: output jumped by %.4f (limit %.4f)", f, i, step, maxStep)
+				}
+			}
+			last[ch] = sample
+		}
+	}
+}
+
+// TestEQChangeDuringFade changes the bands again while the previous change
+// is still crossfading; the half-faded cascade must not be dropped
+func TestEQChangeDuringFade(t *testing.T) {
+	eq, err := NewEQ(testRate, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const freq, amplitude = 1000.0, 0.2
+	boost := []EQBand{{Type: PeakingBand, Frequency: freq, Q: 1, GainDB: 12}}
+	cut := []EQBand{{Type: PeakingBand, Frequency: freq, Q: 1, GainDB: -12}}
+	maxStep := amplitude * math.Pow(10, 12.0/20) * 2 * math.Sin(math.Pi*freq/testRate) * 1.05
+
+	// 5.25ms frames, so each 10ms fade spans two of them and the second
+	// change lands at a peak of the sine
+	frame := make([]float32, 252)
+	var last float32
+	n := 0
+	for f := 0; f < 40; f++ {
+		switch f {
+		case 10:
+			_ = eq.SetBands(boost)
+		case 11:
+			_ = eq.SetBands(cut)
+		}
+
+		for i := range frame {
+			frame[i] = float32(amplitude * math.Sin(2*math.Pi*freq*float64(n)/testRate))
+			n++
+		}
+		_ = eq.Process(frame)
+
+		for i, sample := range frame {
+			if f > 0 || i > 0 {
+				if step := math.Abs(float64(sample - last)); step > maxStep {
+					t.Fatalf("frame %d sample %d: output jumped by %.4f (limit %.4f)", f, i, step, maxStep)
+				}
+			}
+			last = sample
+		}
+	}
+
+	if bands := eq.Bands(); len(bands) != 1 || bands[0] != cut[0] {
+		t.Errorf("Bands() = %+v after the second change, want %+v", bands, cut)
+	}
+}
+
+func TestEQDoesNotAllocate(t *testing.T) {
+	bands, _ := EQPreset("voice-presence")
+	eq, err := NewEQ(testRate, 2, bands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]float32, 960)
+	if allocs := testing.AllocsPerRun(100, func() { _ = eq.Process(frame) }); allocs != 0 {
+		t.Errorf("EQ.Process allocates %.1f times per frame, want 0", allocs)
+	}
+}
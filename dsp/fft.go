@@ -0,0 +1,82 @@
+package dsp
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// FFT computes in-place radix-2 fast Fourier transforms of a fixed size.
+// Twiddle factors and the bit-reversal table are computed once, so
+// transforms don't allocate.
+type FFT struct {
+	size     int
+	twiddles []complex128
+	reversed []int
+}
+
+// NewFFT creates an FFT of the given size, which must be a power of two
+func NewFFT(size int) *FFT {
+	if size < 2 || size&(size-1) != 0 {
+		panic("dsp: FFT size must be a power of two")
+	}
+
+	f := &FFT{
+		size:     size,
+		twiddles: make([]complex128, size/2),
+		reversed: make([]int, size),
+	}
+	for i := range f.twiddles {
+		f.twiddles[i] = cmplx.Exp(complex(0, -2*math.Pi*float64(i)/float64(size)))
+	}
+	shift := bits.UintSize - bits.Len(uint(size-1))
+	for i := range f.reversed {
+		f.reversed[i] = int(bits.Reverse(uint(i)) >> shift)
+	}
+	return f
+}
+
+// Size returns the transform length
+func (f *FFT) Size() int {
+	return f.size
+}
+
+// Forward replaces x (of length Size) with its discrete Fourier transform
+func (f *FFT) Forward(x []complex128) {
+	f.transform(x, false)
+}
+
+// Inverse replaces x with its inverse transform, scaled by 1/Size so that
+// Inverse(Forward(x)) == x
+func (f *FFT) Inverse(x []complex128) {
+	f.transform(x, true)
+	scale := complex(1/float64(f.size), 0)
+	for i := range x {
+		x[i] *= scale
+	}
+}
+
+// transform runs an iterative decimation-in-time FFT
+func (f *FFT) transform(x []complex128, inverse bool) {
+	for i, j := range f.reversed {
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= f.size; size <<= 1 {
+		half := size / 2
+		step := f.size / size
+		for start := 0; start < f.size; start += size {
+			for k := 0; k < half; k++ {
+				w := f.twiddles[k*step]
+				if inverse {
+					w = cmplx.Conj(w)
+				}
+				a, b := x[start+k], x[start+k+half]*w
+				x[start+k] = a + b
+				x[start+k+half] = a - b
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestFFTMatchesDFT(t *testing.T) {
+	const size = 64
+	f := NewFFT(size)
+
+	x := make([]complex128, size)
+	for i := range x {
+		x[i] = complex(math.Sin(float64(i)*0.7)+0.3, math.Cos(float64(i)*1.3))
+	}
+
+	want := make([]complex128, size)
+	for k := range want {
+		for n, v := range x {
+			want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*n)/size))
+		}
+	}
+
+	got := append([]complex128(nil), x...)
+	f.Forward(got)
+	for k := range got {
+		if cmplx.Abs(got[k]-want[k]) > 1e-9 {
+			t.Fatalf("bin %d = %v, want %v", k, got[k], want[k])
+		}
+	}
+
+	f.Inverse(got)
+	for i := range got {
+		if cmplx.Abs(got[i]-x[i]) > 1e-12 {
+			t.Fatalf("round trip sample %d = %v, want %v", i, got[i], x[i])
+		}
+	}
+}
+
+func TestFFTRejectsInvalidSize(t *testing.T) {
+	for _, size := range []int{0, 1, 480, 1000} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewFFT(%d) did not panic", size)
+				}
+			}()
+			NewFFT(size)
+		}()
+	}
+}
+
+func BenchmarkFFT1024(b *testing.B) {
+	f := NewFFT(1024)
+	x := make([]complex128, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f.Forward(x)
+	}
+}
@@ -0,0 +1,39 @@
+package dsp
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// DefaultHighPassCutoff removes DC offset and rumble below the voice range
+const DefaultHighPassCutoff = 80
+
+// butterworthQ gives a maximally flat second-order response
+const butterworthQ = 1 / math.Sqrt2
+
+// HighPass is a Butterworth high-pass Biquad meant to run ahead of the
+// denoiser, removing DC offset and low-frequency rumble
+type HighPass struct {
+	*Biquad
+	sampleRate int
+	cutoff     atomic.Uint64 // float64 bits, Hz
+}
+
+// NewHighPass creates an enabled high-pass filter for interleaved audio
+func NewHighPass(sampleRate, channels int, cutoff float64) *HighPass {
+	h := &HighPass{sampleRate: sampleRate}
+	h.Biquad = NewBiquad(channels, HighPassCoefficients(sampleRate, cutoff, butterworthQ))
+	h.cutoff.Store(math.Float64bits(cutoff))
+	return h
+}
+
+// SetCutoff moves the -3 dB corner frequency; takes effect from the next frame
+func (h *HighPass) SetCutoff(cutoff float64) {
+	h.cutoff.Store(math.Float64bits(cutoff))
+	h.SetCoefficients(HighPassCoefficients(h.sampleRate, cutoff, butterworthQ))
+}
+
+// Cutoff returns the corner frequency in Hz
+func (h *HighPass) Cutoff() float64 {
+	return math.Float64frombits(h.cutoff.Load())
+}
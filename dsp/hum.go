@@ -0,0 +1,399 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxHumHarmonics is the most notches a HumRemover can apply
+	maxHumHarmonics = 40
+	// humDetectRate is the sample rate hum is detected at; only the lowest
+	// harmonics are needed to find the mains frequency
+	humDetectRate = 2000
+	// humDetectHarmonics is how many harmonics are summed during detection
+	humDetectHarmonics = 3
+	// humWindow is how much audio each detection looks at
+	humWindow = time.Second
+	// humUpdate is how often the hum frequency is re-estimated
+	humUpdate = 500 * time.Millisecond
+	// humMaxDrift is how far the mains frequency may stray from 50 or 60 Hz
+	humMaxDrift = 2.0
+	// humMaxSlope is the fastest drift followed, in Hz per second
+	humMaxSlope = 0.5
+	// humMisses is how many detections in a row must miss before the notches
+	// are removed, so a loud word doesn't switch them off
+	humMisses = 4
+
+	// humCoarseSteps and humFineSteps are the candidates tried around each
+	// mains frequency, 0.1 Hz apart and then 0.01 Hz apart
+	humCoarseSteps = int(2*humMaxDrift/0.1) + 1
+	humFineSteps   = 21
+	// humSearchSteps is the work in one detection: every candidate plus the
+	// comparison with the spectrum between harmonics, for each mains
+	humSearchSteps = len(humMains) * (humCoarseSteps + humFineSteps + 1)
+)
+
+// humMains are the mains frequencies hum is searched around
+var humMains = [...]float64{50, 60}
+
+// HumConfig configures a HumRemover
+type HumConfig struct {
+	// Harmonics is how many multiples of the mains frequency are removed,
+	// including the fundamental
+	Harmonics int
+	// BandwidthHz is the width of each notch
+	BandwidthHz float64
+	// DetectionDB is how far the harmonics must stand above the spectrum
+	// between them before hum is considered present
+	DetectionDB float64
+}
+
+// DefaultHumConfig returns settings that remove ground-loop hum while
+// leaving speech harmonics untouched
+func DefaultHumConfig() HumConfig {
+	return HumConfig{
+		Harmonics:   8,
+		BandwidthHz: 3,
+		DetectionDB: 12,
+	}
+}
+
+// HumRemover is a Processor that detects mains hum at 50 or 60 Hz, follows
+// its drift and removes the fundamental and harmonics with a comb of narrow
+// notch filters. Nothing is filtered until hum is detected.
+type HumRemover struct {
+	sampleRate int
+	channels   int
+	bandwidth  float64
+	threshold  float64 // linear power ratio
+
+	enabled   atomic.Bool
+	harmonics atomic.Int32
+	frequency atomic.Uint64 // float64 bits, detected hum in Hz, 0 if none
+
+	// Notch comb, applied by the processing goroutine
+	notches  [maxHumHarmonics]BiquadCoefficients
+	active   int     // notches in use
+	notchHz  float64 // fundamental the notches were designed for
+	notchFor int     // harmonic setting the notches were designed for
+	estimate float64 // last detected fundamental, 0 if none
+	slope    float64 // drift in Hz per second the notches glide with
+	z1, z2   [][maxHumHarmonics]float64
+	running  bool // enabled state seen by the last Process call
+
+	// Detection on a downsampled mono mix
+	decimation int
+	detectRate float64 // sample rate after decimation
+	lowpass    [2]BiquadCoefficients
+	lpState    [2][2]float64
+	phase      int
+	history    []float64 // ring of downsampled audio
+	window     []float64
+	pos        int
+	filled     int
+	sinceCheck int
+	misses     int
+
+	// A detection runs a few candidates per frame, finishing within half of
+	// humUpdate, so no single frame pays for the whole search
+	spread      int       // input samples per channel the search is spread over
+	snapshot    []float64 // windowed history the search runs on
+	searching   bool
+	searchMains int     // index into humMains
+	searchStep  int     // step within the current mains
+	coarse      float64 // best coarse candidate of the current mains
+	candidate   float64 // best candidate so far of the current mains
+	score       float64 // harmonic power at candidate
+	found       float64 // best fundamental over the mains searched
+	foundRatio  float64 // how far found stands above the spectrum between
+}
+
+var _ Processor = (*HumRemover)(nil)
+
+// NewHumRemover creates an enabled HumRemover for interleaved audio with the
+// given sample rate and channel count
+func NewHumRemover(sampleRate, channels int, cfg HumConfig) *HumRemover {
+	h := &HumRemover{
+		sampleRate: sampleRate,
+		bandwidth:  cfg.BandwidthHz,
+		threshold:  math.Pow(10, cfg.DetectionDB/10),
+		decimation: max(1, sampleRate/humDetectRate),
+		running:    true,
+		spread:     max(1, int(humUpdate.Seconds()*float64(sampleRate))/2),
+	}
+	h.detectRate = float64(sampleRate) / float64(h.decimation)
+
+	// Keep speech above the detection band from aliasing onto the harmonics
+	for i := range h.lowpass {
+		h.lowpass[i] = LowPassCoefficients(sampleRate, 0.35*humDetectRate, butterworthQ)
+	}
+	h.history = make([]float64, int(humWindow.Seconds()*h.detectRate))
+	h.window = make([]float64, len(h.history))
+	h.snapshot = make([]float64, len(h.history))
+	for i := range h.window {
+		h.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(h.window)))
+	}
+
+	h.enabled.Store(true)
+	h.SetHarmonics(cfg.Harmonics)
+	h.SetChannels(channels)
+	return h
+}
+
+// SetChannels changes the number of interleaved channels per frame. Must not
+// be called while Process is running.
+func (h *HumRemover) SetChannels(channels int) {
+	h.channels = max(1, channels)
+	h.z1 = make([][maxHumHarmonics]float64, h.channels)
+	h.z2 = make([][maxHumHarmonics]float64, h.channels)
+	h.Reset()
+}
+
+// Process removes hum from one interleaved frame in place
+func (h *HumRemover) Process(frame []float32) error {
+	if len(frame)%h.channels != 0 {
+		return fmt.Errorf("hum remover expects a multiple of %d samples, got %d", h.channels, len(frame))
+	}
+
+	enabled := h.enabled.Load()
+	if enabled != h.running {
+		// Don't let stale notch state from before the bypass ring out
+		h.running = enabled
+		h.Reset()
+	}
+	if !enabled {
+		return nil
+	}
+	if h.slope != 0 {
+		// Follow the drift between estimates
+		h.design(h.notchHz + h.slope*float64(len(frame)/h.channels)/float64(h.sampleRate))
+	} else if int(h.harmonics.Load()) != h.notchFor {
+		h.design(h.notchHz)
+	}
+
+	scale := 1 / float64(h.channels)
+	for i := 0; i < len(frame); i += h.channels {
+		var mono float64
+		for _, sample := range frame[i : i+h.channels] {
+			mono += float64(sample)
+		}
+		h.analyse(mono * scale)
+
+		for ch := 0; ch < h.channels; ch++ {
+			x := float64(frame[i+ch])
+			z1, z2 := &h.z1[ch], &h.z2[ch]
+			for n, c := range h.notches[:h.active] {
+				y := c.B0*x + z1[n]
+				z1[n] = c.B1*x - c.A1*y + z2[n]
+				z2[n] = c.B2*x - c.A2*y
+				x = y
+			}
+			frame[i+ch] = float32(x)
+		}
+	}
+
+	if h.searching {
+		samples := len(frame) / h.channels
+		h.search((humSearchSteps*samples + h.spread - 1) / h.spread)
+	}
+	return nil
+}
+
+// analyse feeds one mono sample to the detector, starting a new estimate of
+// the hum frequency every humUpdate
+func (h *HumRemover) analyse(x float64) {
+	for i, c := range h.lowpass {
+		y := c.B0*x + h.lpState[i][0]
+		h.lpState[i][0] = c.B1*x - c.A1*y + h.lpState[i][1]
+		h.lpState[i][1] = c.B2*x - c.A2*y
+		x = y
+	}
+
+	h.phase++
+	if h.phase < h.decimation {
+		return
+	}
+	h.phase = 0
+
+	h.history[h.pos] = x
+	h.pos = (h.pos + 1) % len(h.history)
+	h.filled = min(h.filled+1, len(h.history))
+	h.sinceCheck++
+	if h.filled == len(h.history) && !h.searching && h.sinceCheck >= int(humUpdate.Seconds()*h.detectRate) {
+		h.sinceCheck = 0
+		h.startSearch()
+	}
+}
+
+// startSearch takes a windowed snapshot of the detection history and starts
+// looking for hum near each mains frequency
+func (h *HumRemover) startSearch() {
+	for i := range h.snapshot {
+		idx := h.pos + i
+		if idx >= len(h.history) {
+			idx -= len(h.history)
+		}
+		h.snapshot[i] = h.history[idx] * h.window[i]
+	}
+	h.searching = true
+	h.searchMains, h.searchStep = 0, 0
+	h.candidate, h.score = humMains[0], -1
+	h.found, h.foundRatio = 0, 0
+}
+
+// search runs up to steps steps of the detection in progress: it finds the
+// fundamental within humMaxDrift of each mains frequency whose harmonics
+// carry the most power, then compares it with the spectrum halfway between
+// the harmonics
+func (h *HumRemover) search(steps int) {
+	for ; steps > 0 && h.searching; steps-- {
+		mains := humMains[h.searchMains]
+		switch step := h.searchStep; {
+		case step < humCoarseSteps:
+			h.try(mains - humMaxDrift + 0.1*float64(step))
+		case step < humCoarseSteps+humFineSteps:
+			if step == humCoarseSteps {
+				h.coarse = h.candidate
+			}
+			h.try(h.coarse - 0.1 + 0.01*float64(step-humCoarseSteps))
+		default:
+			between := (h.harmonicPower(h.candidate, -0.5) + h.harmonicPower(h.candidate, 0.5)) / 2
+			if between > 0 && h.score/between > h.foundRatio {
+				h.found, h.foundRatio = h.candidate, h.score/between
+			}
+
+			h.searchMains++
+			h.searchStep = -1
+			if h.searchMains == len(humMains) {
+				h.searching = false
+				h.detect()
+			} else {
+				h.candidate, h.score = humMains[h.searchMains], -1
+			}
+		}
+		h.searchStep++
+	}
+}
+
+// try keeps f0 as the candidate if its harmonics carry more power
+func (h *HumRemover) try(f0 float64) {
+	if score := h.harmonicPower(f0, 0); score > h.score {
+		h.candidate, h.score = f0, score
+	}
+}
+
+// detect retunes the notches to the finished search's result
+func (h *HumRemover) detect() {
+	if h.foundRatio >= h.threshold {
+		h.misses = 0
+		h.slope = 0
+		if h.estimate > 0 {
+			h.slope = max(-humMaxSlope, min(humMaxSlope, (h.found-h.estimate)/humUpdate.Seconds()))
+		}
+		h.estimate = h.found
+		// The estimate describes the middle of the window; the hum has
+		// drifted on since, including while the search ran
+		elapsed := humWindow.Seconds()/2 + float64(h.sinceCheck)/h.detectRate
+		h.design(h.found + h.slope*elapsed)
+		return
+	}
+
+	h.slope = 0
+	h.misses++
+	if h.misses >= humMisses {
+		h.estimate = 0
+		h.design(0)
+	}
+}
+
+// harmonicPower sums the power at (k+offset)·f0 over the detection harmonics
+func (h *HumRemover) harmonicPower(f0, offset float64) float64 {
+	var sum float64
+	for k := 1; k <= humDetectHarmonics; k++ {
+		sum += h.goertzel((float64(k) + offset) * f0)
+	}
+	return sum
+}
+
+// goertzel returns the power of the search snapshot at freq
+func (h *HumRemover) goertzel(freq float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/h.detectRate)
+	var s1, s2 float64
+	for _, x := range h.snapshot {
+		s := x + coeff*s1 - s2
+		s2, s1 = s1, s
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+// design tunes the notch comb to f0, removing every notch if f0 is 0
+func (h *HumRemover) design(f0 float64) {
+	harmonics := int(h.harmonics.Load())
+	previous := h.active
+
+	h.notchHz, h.notchFor, h.active = f0, harmonics, 0
+	if f0 > 0 {
+		for k := 1; k <= harmonics && float64(k)*f0 < 0.45*float64(h.sampleRate); k++ {
+			center := float64(k) * f0
+			h.notches[k-1] = NotchCoefficients(h.sampleRate, center, center/h.bandwidth)
+			h.active = k
+		}
+	}
+
+	// Notches switched on start from rest
+	for ch := range h.z1 {
+		for n := previous; n < h.active; n++ {
+			h.z1[ch][n], h.z2[ch][n] = 0, 0
+		}
+	}
+	h.frequency.Store(math.Float64bits(f0))
+}
+
+// Frequency returns the detected hum fundamental in Hz, or 0 if no hum is
+// being removed
+func (h *HumRemover) Frequency() float64 {
+	return math.Float64frombits(h.frequency.Load())
+}
+
+// SetHarmonics sets how many multiples of the mains frequency are removed,
+// including the fundamental
+func (h *HumRemover) SetHarmonics(harmonics int) {
+	h.harmonics.Store(int32(min(max(1, harmonics), maxHumHarmonics)))
+}
+
+// Harmonics returns how many multiples of the mains frequency are removed
+func (h *HumRemover) Harmonics() int {
+	return int(h.harmonics.Load())
+}
+
+// SetEnabled turns hum removal on or off
+func (h *HumRemover) SetEnabled(enabled bool) {
+	h.enabled.Store(enabled)
+}
+
+// Enabled reports whether hum removal is active
+func (h *HumRemover) Enabled() bool {
+	return h.enabled.Load()
+}
+
+// Reset forgets the detected hum and clears the filter state
+func (h *HumRemover) Reset() {
+	for ch := range h.z1 {
+		h.z1[ch] = [maxHumHarmonics]float64{}
+		h.z2[ch] = [maxHumHarmonics]float64{}
+	}
+	h.lpState = [2][2]float64{}
+	clear(h.history)
+	h.phase, h.pos, h.filled, h.sinceCheck, h.misses = 0, 0, 0, 0, 0
+	h.searching = false
+	h.estimate, h.slope = 0, 0
+	h.design(0)
+}
+
+// Latency returns 0: the notches are causal IIR filters
+func (h *HumRemover) Latency() int {
+	return 0
+}
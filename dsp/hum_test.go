@@ -0,0 +1,257 @@
+package dsp
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/errakhaoui/noise-canceling/wav"
+)
+
+const humSeconds = 3
+
+// humAmplitudes are the levels of the fundamental and harmonics of the
+// synthetic hum; the odd harmonics are strong, as with rectifier buzz
+var humAmplitudes = []float64{0.08, 0.03, 0.05, 0.015, 0.02, 0.01, 0.008, 0.006}
+
+// humFixture describes a synthetic hum drifting linearly from start to end
+type humFixture struct {
+	name       string
+	start, end float64 // Hz
+}
+
+var humFixtures = []humFixture{
+	{"hum50.wav", 50.2, 50.4},
+	{"hum60.wav", 59.9, 59.7},
+}
+
+// frequencyAt returns the fixture's fundamental at sample i
+func (f humFixture) frequencyAt(i, samples int) float64 {
+	return f.start + (f.end-f.start)*float64(i)/float64(samples)
+}
+
+// synthHum returns hum with the given harmonics drifting from start to end
+// Hz over samples
+func synthHum(start, end float64, samples int, amplitudes []float64) []float32 {
+	out := make([]float32, samples)
+	duration := float64(samples) / testRate
+	for i := range out {
+		t := float64(i) / testRate
+		phase := 2 * math.Pi * (start*t + (end-start)*t*t/(2*duration))
+		var sample float64
+		for k, amplitude := range amplitudes {
+			sample += amplitude * math.Sin(float64(k+1)*phase)
+		}
+		out[i] = float32(sample)
+	}
+	return out
+}
+
+// loadHumFixtures reads the hum fixtures and a voice recording, regenerating
+// them with -update
+func loadHumFixtures(t *testing.T) (hum map[string][]float32, voice []float32) {
+	dir := filepath.Join("testdata", "hum")
+	samples := humSeconds * testRate
+	if *updateFixtures {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		signals := map[string][]float32{"voice.wav": speechLike(5, samples, 0.3, 2.1, 0)}
+		for _, f := range humFixtures {
+			signals[f.name] = synthHum(f.start, f.end, samples, humAmplitudes)
+		}
+		for name, signal := range signals {
+			if err := wav.Write(filepath.Join(dir, name), &wav.Audio{SampleRate: testRate, Channels: 1, Samples: signal}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	load := func(name string) []float32 {
+		audio, err := wav.Read(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%v (run go test -update to create the fixtures)", err)
+		}
+		if audio.SampleRate != testRate || audio.Channels != 1 || len(audio.Samples) != samples {
+			t.Fatalf("%s is %d Hz with %d channels and %d samples, want %d Hz mono with %d", name, audio.SampleRate, audio.Channels, len(audio.Samples), testRate, samples)
+		}
+		return audio.Samples
+	}
+
+	hum = make(map[string][]float32)
+	for _, f := range humFixtures {
+		hum[f.name] = load(f.name)
+	}
+	return hum, load("voice.wav")
+}
+
+func TestHumRemoverRemovesHum(t *testing.T) {
+	hum, voice := loadHumFixtures(t)
+	samples := humSeconds * testRate
+	last := samples - testRate // Measured over the last second
+
+	for _, f := range humFixtures {
+		t.Run(f.name, func(t *testing.T) {
+			h := NewHumRemover(testRate, 1, DefaultHumConfig())
+			out := append([]float32(nil), hum[f.name]...)
+			processInFrames(t, h, out)
+
+			if got := h.Frequency(); math.Abs(got-f.end) > 0.05 {
+				t.Errorf("Frequency() = %.2f Hz, want %.2f Hz", got, f.end)
+			}
+			if attenuation := toDB(rms(hum[f.name][last:]) / rms(out[last:])); attenuation < 30 {
+				t.Errorf("hum attenuated by %.1f dB, want at least 30 dB", attenuation)
+			}
+
+			// Every harmonic must go, not just the loud fundamental
+			window := out[samples-testRate/2:]
+			freq := f.frequencyAt(samples-testRate/4, samples)
+			for k, amplitude := range humAmplitudes {
+				residual, _ := fitTone(window, float64(k+1)*freq, testRate)
+				if attenuation := toDB(amplitude / residual); attenuation < 25 {
+					t.Errorf("harmonic %d attenuated by %.1f dB, want at least 25 dB", k+1, attenuation)
+				}
+			}
+		})
+
+		t.Run(f.name+"+voice", func(t *testing.T) {
+			h := NewHumRemover(testRate, 1, DefaultHumConfig())
+			out := make([]float32, samples)
+			for i := range out {
+				out[i] = hum[f.name][i] + voice[i]
+			}
+			processInFrames(t, h, out)
+
+			if got := h.Frequency(); math.Abs(got-f.end) > 0.1 {
+				t.Errorf("Frequency() = %.2f Hz with voice, want %.2f Hz", got, f.end)
+			}
+			if change := toDB(rms(out[last:]) / rms(voice[last:])); math.Abs(change) > 0.5 {
+				t.Errorf("voice level changed by %+.2f dB, want within 0.5 dB", change)
+			}
+		})
+	}
+}
+
+func TestHumRemoverIgnoresVoice(t *testing.T) {
+	_, voice := loadHumFixtures(t)
+	h := NewHumRemover(testRate, 1, DefaultHumConfig())
+	out := append([]float32(nil), voice...)
+	processInFrames(t, h, out)
+
+	if got := h.Frequency(); got != 0 {
+		t.Errorf("Frequency() = %.2f Hz for voice without hum, want 0", got)
+	}
+	for i := range out {
+		if out[i] != voice[i] {
+			t.Fatalf("sample %d = %v, want %v unchanged", i, out[i], voice[i])
+		}
+	}
+}
+
+func TestHumRemoverTracksDrift(t *testing.T) {
+	samples := 8 * testRate
+	h := NewHumRemover(testRate, 2, DefaultHumConfig())
+	mono := synthHum(59.4, 60.6, samples, humAmplitudes[:3])
+
+	frame := make([]float32, 960)
+	for f := 0; f < samples/480; f++ {
+		for i := 0; i < 480; i++ {
+			frame[2*i], frame[2*i+1] = mono[f*480+i], 0.5*mono[f*480+i]
+		}
+		if err := h.Process(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		if f%100 == 99 && f >= 200 {
+			want := 59.4 + 1.2*float64((f+1)*480)/float64(samples)
+			if got := h.Frequency(); math.Abs(got-want) > 0.1 {
+				t.Errorf("at %.1fs Frequency() = %.2f Hz, want %.2f Hz", float64(f+1)/100, got, want)
+			}
+		}
+	}
+}
+
+func TestHumRemoverHarmonics(t *testing.T) {
+	samples := 3 * testRate
+	hum := synthHum(50, 50, samples, humAmplitudes)
+
+	tests := []struct {
+		harmonics int
+		removed   []bool // per harmonic of humAmplitudes
+	}{
+		{1, []bool{true, false, false}},
+		{2, []bool{true, true, false}},
+		{8, []bool{true, true, true, true, true, true, true, true}},
+	}
+
+	for _, tt := range tests {
+		h := NewHumRemover(testRate, 1, DefaultHumConfig())
+		h.SetHarmonics(tt.harmonics)
+		out := append([]float32(nil), hum...)
+		processInFrames(t, h, out)
+
+		window := out[samples-testRate/2:]
+		for k, removed := range tt.removed {
+			residual, _ := fitTone(window, float64(k+1)*50, testRate)
+			attenuation := toDB(humAmplitudes[k] / residual)
+			if removed && attenuation < 25 {
+				t.Errorf("%d harmonics: harmonic %d attenuated by %.1f dB, want at least 25 dB", tt.harmonics, k+1, attenuation)
+			}
+			if !removed && math.Abs(attenuation) > 0.5 {
+				t.Errorf("%d harmonics: harmonic %d changed by %.1f dB, want untouched", tt.harmonics, k+1, -attenuation)
+			}
+		}
+	}
+}
+
+func TestHumRemoverSpreadsDetection(t *testing.T) {
+	h := NewHumRemover(testRate, 1, DefaultHumConfig())
+	hum := synthHum(50, 50, 2*testRate, humAmplitudes)
+
+	started, found := -1, -1
+	for f := 0; f < len(hum)/480 && found < 0; f++ {
+		if err := h.Process(hum[f*480 : (f+1)*480]); err != nil {
+			t.Fatal(err)
+		}
+		if started < 0 && h.searching {
+			started = f
+		}
+		if h.Frequency() != 0 {
+			found = f
+		}
+	}
+
+	if started < 0 || found < 0 {
+		t.Fatalf("search started at frame %d and found hum at frame %d, want both", started, found)
+	}
+	// 10ms frames, finishing within half of humUpdate
+	if frames := found - started; frames < 2 || frames > 25 {
+		t.Errorf("detection took %d frames, want it spread over 2 to 25", frames)
+	}
+}
+
+func TestHumRemoverDoesNotAllocate(t *testing.T) {
+	h := NewHumRemover(testRate, 2, DefaultHumConfig())
+	mono := synthHum(50, 50, testRate, humAmplitudes)
+	frame := make([]float32, 960)
+	n := 0
+	next := func() {
+		for i := 0; i < 480; i++ {
+			frame[2*i], frame[2*i+1] = mono[n], mono[n]
+			n = (n + 1) % len(mono)
+		}
+		_ = h.Process(frame)
+	}
+
+	// Fill the detector so estimates run during the measurement
+	for i := 0; i < 150; i++ {
+		next()
+	}
+	if allocs := testing.AllocsPerRun(100, next); allocs != 0 {
+		t.Errorf("HumRemover allocates %.1f times per frame, want 0", allocs)
+	}
+	if h.Frequency() == 0 {
+		t.Error("hum was not detected during the measurement")
+	}
+}
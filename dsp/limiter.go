@@ -0,0 +1,211 @@
+package dsp
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// LimiterConfig configures the brickwall limiter
+type LimiterConfig struct {
+	// CeilingDB is the highest peak level the limiter lets through, in dBFS
+	CeilingDB float32
+	// Lookahead is how far ahead the limiter sees peaks coming; it is also
+	// the delay the limiter adds
+	Lookahead time.Duration
+	// Release is how quickly the gain recovers after a peak
+	Release time.Duration
+}
+
+// DefaultLimiterConfig returns a -1 dBFS ceiling with 5ms of lookahead
+func DefaultLimiterConfig() LimiterConfig {
+	return LimiterConfig{
+		CeilingDB: -1,
+		Lookahead: 5 * time.Millisecond,
+		Release:   60 * time.Millisecond,
+	}
+}
+
+// Limiter is a lookahead brickwall limiter Processor, meant to be the last
+// stage before the output. Audio is delayed by the lookahead so the gain can
+// ramp down smoothly before a peak arrives rather than clipping it; no
+// sample leaves above the ceiling. All channels share one gain.
+//
+// While disabled the audio is still delayed, so toggling the limiter doesn't
+// change the latency or click.
+type Limiter struct {
+	channels    int
+	lookahead   int // in samples per channel
+	releaseCoef float64
+
+	enabled   atomic.Bool
+	ceiling   atomic.Uint32 // float32 bits, linear
+	meterBits atomic.Uint32 // float32 bits, peak gain reduction of the last frame in dB
+
+	delay    []float32 // interleaved ring of lookahead sample frames
+	delayPos int
+
+	// Sliding minimum of the required gain over lookahead+1 samples, as a
+	// monotonic deque in a ring buffer
+	minGains   []float64
+	minIndices []int
+	minHead    int
+	minLen     int
+	sample     int
+
+	released float64 // required gain after release smoothing
+
+	// Moving average of the released gain over lookahead samples, which
+	// turns steps into ramps that complete as the peak leaves the delay
+	average    []float64
+	averagePos int
+	averageSum float64
+}
+
+var _ Processor = (*Limiter)(nil)
+
+// NewLimiter creates an enabled limiter for interleaved audio with the given
+// sample rate and channel count
+func NewLimiter(sampleRate, channels int, cfg LimiterConfig) *Limiter {
+	l := &Limiter{
+		lookahead:   max(1, int(cfg.Lookahead.Seconds()*float64(sampleRate))),
+		releaseCoef: smoothingCoef(cfg.Release, sampleRate),
+	}
+	l.SetCeilingDB(cfg.CeilingDB)
+	l.enabled.Store(true)
+	l.minGains = make([]float64, l.lookahead+1)
+	l.minIndices = make([]int, l.lookahead+1)
+	l.average = make([]float64, l.lookahead)
+	l.SetChannels(channels)
+	return l
+}
+
+// Process limits one interleaved frame in place. The output is delayed by
+// Latency samples.
+func (l *Limiter) Process(frame []float32) error {
+	enabled := l.enabled.Load()
+	ceiling := math.Float32frombits(l.ceiling.Load())
+
+	minGain := 1.0
+	for i := 0; i < len(frame); i += l.channels {
+		end := min(i+l.channels, len(frame))
+
+		required := 1.0
+		if enabled {
+			var peak float32
+			for _, sample := range frame[i:end] {
+				peak = max(peak, abs32(sample))
+			}
+			if peak > ceiling {
+				required = float64(ceiling / peak)
+			}
+		}
+		gain := l.nextGain(required)
+		minGain = min(minGain, gain)
+
+		delayed := l.delay[l.delayPos*l.channels:]
+		for c := range frame[i:end] {
+			out := delayed[c] * float32(gain)
+			delayed[c] = frame[i+c]
+			if enabled {
+				// Guard against rounding in the gain ramp
+				out = max(-ceiling, min(ceiling, out))
+			}
+			frame[i+c] = out
+		}
+		l.delayPos = (l.delayPos + 1) % l.lookahead
+	}
+
+	l.meterBits.Store(math.Float32bits(-linearToDB(float32(minGain))))
+	return nil
+}
+
+// nextGain takes the gain the newest sample needs and returns the gain for
+// the sample leaving the delay line, which is never above what any sample
+// still in the delay line needs
+func (l *Limiter) nextGain(required float64) float64 {
+	size := len(l.minGains)
+
+	// Drop larger gains from the back; they can never be the minimum again
+	for l.minLen > 0 {
+		back := (l.minHead + l.minLen - 1) % size
+		if l.minGains[back] < required {
+			break
+		}
+		l.minLen--
+	}
+	back := (l.minHead + l.minLen) % size
+	l.minGains[back] = required
+	l.minIndices[back] = l.sample
+	l.minLen++
+
+	// Drop the front once it has left the window
+	if l.minIndices[l.minHead] < l.sample-l.lookahead {
+		l.minHead = (l.minHead + 1) % size
+		l.minLen--
+	}
+	l.sample++
+	hold := l.minGains[l.minHead]
+
+	// Release smoothly, but follow drops instantly
+	l.released = hold - (hold-l.released)*l.releaseCoef
+	l.released = min(l.released, hold)
+
+	l.averageSum += l.released - l.average[l.averagePos]
+	l.average[l.averagePos] = l.released
+	l.averagePos = (l.averagePos + 1) % l.lookahead
+	return l.averageSum / float64(l.lookahead)
+}
+
+// SetChannels changes the number of interleaved channels and clears the
+// delay line. Must not be called while Process is running.
+func (l *Limiter) SetChannels(channels int) {
+	l.channels = max(1, channels)
+	l.delay = make([]float32, l.lookahead*l.channels)
+	l.Reset()
+}
+
+// Reset clears the delay line and releases any gain reduction
+func (l *Limiter) Reset() {
+	clear(l.delay)
+	l.delayPos = 0
+	l.minHead, l.minLen, l.sample = 0, 0, 0
+	l.released = 1
+	for i := range l.average {
+		l.average[i] = 1
+	}
+	l.averagePos = 0
+	l.averageSum = float64(l.lookahead)
+	l.meterBits.Store(0)
+}
+
+// Latency returns the lookahead delay in samples per channel
+func (l *Limiter) Latency() int {
+	return l.lookahead
+}
+
+// SetEnabled turns limiting on or off; the lookahead delay stays either way
+func (l *Limiter) SetEnabled(enabled bool) {
+	l.enabled.Store(enabled)
+}
+
+// Enabled reports whether the limiter is active
+func (l *Limiter) Enabled() bool {
+	return l.enabled.Load()
+}
+
+// SetCeilingDB sets the maximum output peak level in dBFS (at most 0)
+func (l *Limiter) SetCeilingDB(db float32) {
+	l.ceiling.Store(math.Float32bits(dbToLinear(min(0, db))))
+}
+
+// CeilingDB returns the maximum output peak level in dBFS
+func (l *Limiter) CeilingDB() float32 {
+	return linearToDB(math.Float32frombits(l.ceiling.Load()))
+}
+
+// GainReductionDB returns the largest gain reduction applied during the last
+// frame, in dB (0 when not limiting)
+func (l *Limiter) GainReductionDB() float32 {
+	return math.Float32frombits(l.meterBits.Load())
+}
@@ -0,0 +1,127 @@
+package dsp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLimiterNeverExceedsCeiling(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		l := NewLimiter(testRate, channels, DefaultLimiterConfig())
+		ceiling := float64(dbToLinear(-1))
+		rng := rand.New(rand.NewSource(1))
+
+		frame := make([]float32, 480*channels)
+		for f := 0; f < 200; f++ {
+			// Speech-like level changes with occasional loud bursts
+			level := 0.2 + rng.Float64()
+			if f%17 == 0 {
+				level = 4
+			}
+			for i := range frame {
+				frame[i] = float32(rng.NormFloat64() * level)
+			}
+			_ = l.Process(frame)
+			for i, sample := range frame {
+				if math.Abs(float64(sample)) > ceiling {
+					t.Fatalf("%d channels, frame %d sample %d = %.4f, above the %.4f ceiling", channels, f, i, sample, ceiling)
+				}
+			}
+		}
+	}
+}
+
+func TestLimiterTransparentBelowCeiling(t *testing.T) {
+	l := NewLimiter(testRate, 1, DefaultLimiterConfig())
+	delay := l.Latency()
+	if delay != 240 {
+		t.Fatalf("Latency() = %d, want 240 samples of lookahead", delay)
+	}
+
+	const frames = 10
+	in := make([]float32, frames*480)
+	for i := range in {
+		in[i] = float32(0.5 * math.Sin(2*math.Pi*440*float64(i)/testRate))
+	}
+	out := make([]float32, len(in))
+	copy(out, in)
+	for f := 0; f < frames; f++ {
+		_ = l.Process(out[f*480 : (f+1)*480])
+	}
+
+	for i := delay; i < len(out); i++ {
+		if math.Abs(float64(out[i]-in[i-delay])) > 1e-6 {
+			t.Fatalf("sample %d = %v, want the input delayed by %d samples (%v)", i, out[i], delay, in[i-delay])
+		}
+	}
+	if gr := l.GainReductionDB(); gr != 0 {
+		t.Errorf("GainReductionDB() = %.2f below the ceiling, want 0", gr)
+	}
+}
+
+func TestLimiterRampsBeforePeak(t *testing.T) {
+	l := NewLimiter(testRate, 1, DefaultLimiterConfig())
+	delay := l.Latency()
+
+	// A single full-scale click in quiet audio
+	in := make([]float32, 1440)
+	for i := range in {
+		in[i] = 0.1
+	}
+	in[720] = 1
+	out := make([]float32, len(in))
+	copy(out, in)
+	for f := 0; f < 3; f++ {
+		_ = l.Process(out[f*480 : (f+1)*480])
+	}
+
+	// Gain reaches the ceiling exactly when the click leaves the delay line,
+	// ramping down over the lookahead instead of stepping
+	peak := 720 + delay
+	ceiling := dbToLinear(-1)
+	if math.Abs(float64(out[peak]-ceiling)) > 1e-5 {
+		t.Errorf("click output = %.4f, want the %.4f ceiling", out[peak], ceiling)
+	}
+	for i := peak - delay + 1; i < peak; i++ {
+		step := math.Abs(float64(out[i] - out[i-1]))
+		if step > 0.001 {
+			t.Fatalf("gain steps by %.4f at sample %d, want a smooth ramp", step, i)
+		}
+	}
+	// The click is 1 dB over the ceiling
+	if gr := l.GainReductionDB(); math.Abs(float64(gr)-1) > 0.01 {
+		t.Errorf("GainReductionDB() = %.2f, want 1 dB", gr)
+	}
+}
+
+func TestLimiterDisabledKeepsDelay(t *testing.T) {
+	l := NewLimiter(testRate, 1, DefaultLimiterConfig())
+	l.SetEnabled(false)
+
+	frame := make([]float32, 480)
+	for i := range frame {
+		frame[i] = 2
+	}
+	_ = l.Process(frame)
+	for i, sample := range frame {
+		want := float32(0)
+		if i >= l.Latency() {
+			want = 2
+		}
+		if sample != want {
+			t.Fatalf("sample %d = %v, want %v", i, sample, want)
+		}
+	}
+}
+
+func TestLimiterDoesNotAllocate(t *testing.T) {
+	l := NewLimiter(testRate, 2, DefaultLimiterConfig())
+	frame := make([]float32, 960)
+	for i := range frame {
+		frame[i] = 2
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _ = l.Process(frame) }); allocs != 0 {
+		t.Errorf("Limiter.Process allocates %.1f times per frame, want 0", allocs)
+	}
+}
@@ -0,0 +1,131 @@
+package dsp
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Processor is one stage of the audio pipeline. Frames hold normalised
+// (-1 to 1) float32 samples, interleaved when there is more than one channel,
+// and are processed in place.
+type Processor interface {
+	// Process transforms one frame in place
+	Process(frame []float32) error
+	// Reset clears any internal state, e.g. after the input device changes
+	Reset()
+	// Latency returns the delay in samples per channel that the stage adds
+	Latency() int
+}
+
+// Chain runs a list of Processors in order. Stages can be rearranged while
+// audio is running: changes take effect from the next frame and Process never
+// blocks on them.
+type Chain struct {
+	stages atomic.Pointer[[]Processor]
+}
+
+// NewChain creates a chain running stages in the given order
+func NewChain(stages ...Processor) *Chain {
+	c := &Chain{}
+	c.SetStages(stages...)
+	return c
+}
+
+// Stages returns a copy of the stages in processing order
+func (c *Chain) Stages() []Processor {
+	return append([]Processor(nil), c.load()...)
+}
+
+// SetStages replaces all stages
+func (c *Chain) SetStages(stages ...Processor) {
+	stages = append([]Processor(nil), stages...)
+	c.stages.Store(&stages)
+}
+
+// Append adds a stage to the end of the chain
+func (c *Chain) Append(p Processor) {
+	c.update(func(current []Processor) []Processor {
+		return append(append([]Processor(nil), current...), p)
+	})
+}
+
+// Insert adds a stage at position index (0 runs it first). Indexes outside
+// the chain are clamped to its ends.
+func (c *Chain) Insert(index int, p Processor) {
+	c.update(func(current []Processor) []Processor {
+		index := max(0, min(index, len(current)))
+		stages := make([]Processor, 0, len(current)+1)
+		stages = append(stages, current[:index]...)
+		stages = append(stages, p)
+		return append(stages, current[index:]...)
+	})
+}
+
+// Remove takes the first occurrence of p out of the chain and reports
+// whether it was found
+func (c *Chain) Remove(p Processor) bool {
+	found := false
+	c.update(func(current []Processor) []Processor {
+		found = false
+		for i, stage := range current {
+			if stage == p {
+				found = true
+				stages := make([]Processor, 0, len(current)-1)
+				stages = append(stages, current[:i]...)
+				return append(stages, current[i+1:]...)
+			}
+		}
+		return current
+	})
+	return found
+}
+
+// Process runs every stage on frame in order, stopping at the first error
+func (c *Chain) Process(frame []float32) error {
+	for i, stage := range c.load() {
+		if err := stage.Process(frame); err != nil {
+			return fmt.Errorf("stage %d (%T): %w", i, stage, err)
+		}
+	}
+	return nil
+}
+
+// Reset resets every stage
+func (c *Chain) Reset() {
+	for _, stage := range c.load() {
+		stage.Reset()
+	}
+}
+
+// Latency returns the total latency of all stages
+func (c *Chain) Latency() int {
+	total := 0
+	for _, stage := range c.load() {
+		total += stage.Latency()
+	}
+	return total
+}
+
+// update replaces the stage list with fn's result, retrying if another
+// goroutine changed the chain in the meantime. fn must not modify its input.
+func (c *Chain) update(fn func(current []Processor) []Processor) {
+	for {
+		old := c.stages.Load()
+		var current []Processor
+		if old != nil {
+			current = *old
+		}
+		stages := fn(current)
+		if c.stages.CompareAndSwap(old, &stages) {
+			return
+		}
+	}
+}
+
+// load returns the current stage list, which must not be modified
+func (c *Chain) load() []Processor {
+	if stages := c.stages.Load(); stages != nil {
+		return *stages
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package dsp
+
+import (
+	"errors"
+	"testing"
+)
+
+// recorder is a test stage that appends its name to a shared log
+type recorder struct {
+	name    string
+	log     *[]string
+	latency int
+	resets  int
+	err     error
+}
+
+func (r *recorder) Process(frame []float32) error {
+	*r.log = append(*r.log, r.name)
+	return r.err
+}
+
+func (r *recorder) Reset()       { r.resets++ }
+func (r *recorder) Latency() int { return r.latency }
+
+// gain is a test stage that scales every sample
+type gain float32
+
+func (g gain) Process(frame []float32) error {
+	for i := range frame {
+		frame[i] *= float32(g)
+	}
+	return nil
+}
+
+func (g gain) Reset()       {}
+func (g gain) Latency() int { return 0 }
+
+func TestChainOrder(t *testing.T) {
+	var log []string
+	a := &recorder{name: "a", log: &log}
+	b := &recorder{name: "b", log: &log}
+	c := &recorder{name: "c", log: &log}
+
+	chain := NewChain(b)
+	chain.Insert(0, a)
+	chain.Append(c)
+
+	if err := chain.Process(make([]float32, 4)); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if got := len(log); got != 3 || log[0] != "a" || log[1] != "b" || log[2] != "c" {
+		t.Fatalf("stages ran as %v, want [a b c]", log)
+	}
+
+	log = nil
+	if !chain.Remove(b) {
+		t.Fatal("Remove(b) = false, want true")
+	}
+	if chain.Remove(b) {
+		t.Error("Remove(b) twice = true, want false")
+	}
+	_ = chain.Process(make([]float32, 4))
+	if len(log) != 2 || log[0] != "a" || log[1] != "c" {
+		t.Errorf("stages ran as %v after removal, want [a c]", log)
+	}
+}
+
+func TestChainProcessesInPlace(t *testing.T) {
+	chain := NewChain(gain(2), gain(0.25))
+	frame := []float32{1, -1, 0.5}
+	if err := chain.Process(frame); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	for i, want := range []float32{0.5, -0.5, 0.25} {
+		if frame[i] != want {
+			t.Errorf("frame[%d] = %v, want %v", i, frame[i], want)
+		}
+	}
+}
+
+func TestChainStopsOnError(t *testing.T) {
+	var log []string
+	failure := errors.New("broken")
+	chain := NewChain(
+		&recorder{name: "a", log: &log, err: failure},
+		&recorder{name: "b", log: &log},
+	)
+
+	err := chain.Process(make([]float32, 4))
+	if !errors.Is(err, failure) {
+		t.Fatalf("Process() error = %v, want %v", err, failure)
+	}
+	if len(log) != 1 {
+		t.Errorf("stages ran as %v, want only [a]", log)
+	}
+}
+
+func TestChainResetAndLatency(t *testing.T) {
+	var log []string
+	a := &recorder{name: "a", log: &log, latency: 480}
+	b := &recorder{name: "b", log: &log, latency: 64}
+	chain := NewChain(a, b)
+
+	if got := chain.Latency(); got != 544 {
+		t.Errorf("Latency() = %d, want 544", got)
+	}
+
+	chain.Reset()
+	if a.resets != 1 || b.resets != 1 {
+		t.Errorf("resets = %d/%d, want 1/1", a.resets, b.resets)
+	}
+
+	// Chains nest as a single stage
+	outer := NewChain(chain, &recorder{name: "c", log: &log, latency: 10})
+	if got := outer.Latency(); got != 554 {
+		t.Errorf("nested Latency() = %d, want 554", got)
+	}
+}
+
+func TestChainDoesNotAllocate(t *testing.T) {
+	chain := NewChain(gain(0.5), gain(2))
+	frame := make([]float32, 480)
+	if allocs := testing.AllocsPerRun(100, func() { _ = chain.Process(frame) }); allocs != 0 {
+		t.Errorf("Chain.Process allocates %.1f times per frame, want 0", allocs)
+	}
+}
+
+// TestStagesResetToInitialState checks that after Reset every stage behaves
+// exactly like a freshly created one, without allocating
+func TestStagesResetToInitialState(t *testing.T) {
+	eqBands := []EQBand{{Type: PeakingBand, Frequency: 3000, Q: 1, GainDB: 6}}
+	aggressive := DefaultCompressorConfig()
+	aggressive.ThresholdDB = -40
+
+	tests := []struct {
+		name    string
+		newFunc func() Processor
+	}{
+		{"HighPass", func() Processor { return NewHighPass(testRate, 2, DefaultHighPassCutoff) }},
+		{"EQ", func() Processor {
+			eq, err := NewEQ(testRate, 2, eqBands)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return eq
+		}},
+		{"AGC", func() Processor { return NewAGC(testRate, 2, DefaultAGCConfig(), nil) }},
+		{"Compressor", func() Processor { return NewCompressor(testRate, 2, aggressive) }},
+		{"Limiter", func() Processor { return NewLimiter(testRate, 2, DefaultLimiterConfig()) }},
+		{"AEC", func() Processor { return NewAEC(testRate, 2, DefaultAECConfig()) }},
+		{"HumRemover", func() Processor { return NewHumRemover(testRate, 2, DefaultHumConfig()) }},
+		{"ClickSuppressor", func() Processor { return NewClickSuppressor(testRate, 2, DefaultClickConfig(), nil) }},
+		{"ComfortNoise", func() Processor {
+			e := NewNoiseEstimator(testRate, 2)
+			return NewChain(e, NewComfortNoise(testRate, 2, DefaultComfortNoiseConfig(), e, fixedVoice(0)))
+		}},
+		{"NoiseSubtractor", func() Processor {
+			s := NewNoiseSubtractor(testRate, 2, DefaultSubtractionConfig())
+			profile := &NoiseProfile{SampleRate: testRate, FFTSize: subtractionFFTSize, Power: make([]float64, subtractionFFTSize/2+1)}
+			for k := range profile.Power {
+				profile.Power[k] = 0.01
+			}
+			if err := s.SetProfile(profile); err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}},
+	}
+
+	far := speechLike(1, 40*480, 0.6, 2.5, 0)
+	mic := speechLike(2, 40*480*2, 0.8, 1.7, 1)
+	run := func(p Processor, from, frames int) []float32 {
+		out := append([]float32(nil), mic[from*960:(from+frames)*960]...)
+		for f := 0; f < frames; f++ {
+			if aec, ok := p.(*AEC); ok {
+				if err := aec.SetReference(far[(from+f)*480 : (from+f+1)*480]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := p.Process(out[f*960 : (f+1)*960]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return out
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used := tt.newFunc()
+			run(used, 0, 30)
+			if allocs := testing.AllocsPerRun(10, used.Reset); allocs != 0 {
+				t.Errorf("Reset allocates %.1f times, want 0", allocs)
+			}
+
+			got := run(used, 30, 10)
+			want := run(tt.newFunc(), 30, 10)
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("sample %d after Reset = %v, want %v as from a new stage", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Package dsp provides pure-Go signal processing building blocks shared by
+// the audio pipeline
+package dsp
+
+import (
+	"math"
+)
+
+const (
+	// resamplerPassband is the fraction of the lower Nyquist frequency kept flat
+	resamplerPassband = 0.9
+	// resamplerAttenuationDB is the stopband rejection of the anti-aliasing filter
+	resamplerAttenuationDB = 100
+)
+
+// Resampler converts a mono stream between two sample rates using a
+// polyphase windowed-sinc (Kaiser) low-pass filter
+type Resampler struct {
+	inRate, outRate int
+	up, down        int         // rational ratio outRate/inRate = up/down
+	phases          [][]float32 // polyphase filter bank, phases[p][j]
+	taps            int         // taps per phase
+
+	history []float32 // doubled ring buffer so the window is contiguous
+	pos     int       // index of the newest sample in history
+	phase   int       // offset of the next output relative to the newest input
+}
+
+// NewResampler creates a resampler from inRate to outRate (both in Hz)
+func NewResampler(inRate, outRate int) *Resampler {
+	g := gcd(inRate, outRate)
+	r := &Resampler{
+		inRate:  inRate,
+		outRate: outRate,
+		up:      outRate / g,
+		down:    inRate / g,
+	}
+	r.design()
+	r.history = make([]float32, 2*r.taps)
+	return r
+}
+
+// design builds the polyphase filter bank for the prototype low-pass filter
+// running at the intermediate rate up*inRate
+func (r *Resampler) design() {
+	interRate := float64(r.up) * float64(r.inRate)
+	nyquist := float64(min(r.inRate, r.outRate)) / 2
+	passEdge := nyquist * resamplerPassband
+	cutoff := (passEdge + nyquist) / 2 / interRate // normalised to interRate
+	transition := 2 * math.Pi * (nyquist - passEdge) / interRate
+
+	// Kaiser filter length and shape for the requested attenuation
+	length := int(math.Ceil((resamplerAttenuationDB - 8) / (2.285 * transition)))
+	r.taps = (length + r.up - 1) / r.up
+	length = r.taps * r.up
+	beta := 0.1102 * (resamplerAttenuationDB - 8.7)
+
+	prototype := make([]float64, length)
+	center := float64(length-1) / 2
+	sum := 0.0
+	for k := range prototype {
+		t := float64(k) - center
+		ratio := 2 * t / float64(length-1)
+		window := besselI0(beta*math.Sqrt(1-ratio*ratio)) / besselI0(beta)
+		prototype[k] = 2 * cutoff * sinc(2*cutoff*t) * window
+		sum += prototype[k]
+	}
+
+	// Zero-stuffing divides the gain by up, so scale back to unity at DC
+	r.phases = make([][]float32, r.up)
+	for p := range r.phases {
+		r.phases[p] = make([]float32, r.taps)
+		for j := range r.phases[p] {
+			r.phases[p][j] = float32(prototype[p+j*r.up] * float64(r.up) / sum)
+		}
+	}
+}
+
+// MaxOutput returns the most samples Process can produce for n input samples
+func (r *Resampler) MaxOutput(n int) int {
+	return (n*r.up)/r.down + 1
+}
+
+// Process resamples in and writes the result to out, returning the number of
+// samples written. out must hold at least MaxOutput(len(in)) samples.
+func (r *Resampler) Process(in, out []float32) int {
+	n := 0
+	for _, x := range in {
+		// Push the sample so history[pos:pos+taps] runs newest to oldest
+		r.pos--
+		if r.pos < 0 {
+			r.pos = r.taps - 1
+		}
+		r.history[r.pos] = x
+		r.history[r.pos+r.taps] = x
+		window := r.history[r.pos : r.pos+r.taps]
+
+		for r.phase < r.up {
+			coeffs := r.phases[r.phase]
+			var acc float32
+			for j, c := range coeffs {
+				acc += c * window[j]
+			}
+			out[n] = acc
+			n++
+			r.phase += r.down
+		}
+		r.phase -= r.up
+	}
+	return n
+}
+
+// Latency returns the filter delay in output samples
+func (r *Resampler) Latency() int {
+	return int(math.Round(float64(r.taps*r.up-1) / 2 / float64(r.down)))
+}
+
+// Reset clears the filter history without reallocating
+func (r *Resampler) Reset() {
+	clear(r.history)
+	r.pos = 0
+	r.phase = 0
+}
+
+// InRate returns the input sample rate in Hz
+func (r *Resampler) InRate() int {
+	return r.inRate
+}
+
+// OutRate returns the output sample rate in Hz
+func (r *Resampler) OutRate() int {
+	return r.outRate
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 evaluates the zeroth-order modified Bessel function of the first kind
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// InterleavedResampler resamples interleaved multi-channel audio with one
+// Resampler per channel
+type InterleavedResampler struct {
+	channels  []*Resampler
+	planarIn  [][]float32
+	planarOut [][]float32
+}
+
+// NewInterleavedResampler creates a resampler for the given channel count.
+// maxFrames is the largest number of frames passed to a single Process call.
+func NewInterleavedResampler(inRate, outRate, channels, maxFrames int) *InterleavedResampler {
+	r := &InterleavedResampler{}
+	for c := 0; c < channels; c++ {
+		resampler := NewResampler(inRate, outRate)
+		r.channels = append(r.channels, resampler)
+		r.planarIn = append(r.planarIn, make([]float32, maxFrames))
+		r.planarOut = append(r.planarOut, make([]float32, resampler.MaxOutput(maxFrames)))
+	}
+	return r
+}
+
+// MaxOutput returns the most samples (not frames) Process can produce for n
+// input samples
+func (r *InterleavedResampler) MaxOutput(n int) int {
+	channels := len(r.channels)
+	return r.channels[0].MaxOutput(n/channels) * channels
+}
+
+// Process resamples interleaved in and writes interleaved audio to out,
+// returning the number of samples written
+func (r *InterleavedResampler) Process(in, out []float32) int {
+	channels := len(r.channels)
+	frames := len(in) / channels
+
+	n := 0
+	for c, resampler := range r.channels {
+		for i := 0; i < frames; i++ {
+			r.planarIn[c][i] = in[i*channels+c]
+		}
+		n = resampler.Process(r.planarIn[c][:frames], r.planarOut[c])
+	}
+
+	for c := range r.channels {
+		for i := 0; i < n; i++ {
+			out[i*channels+c] = r.planarOut[c][i]
+		}
+	}
+	return n * channels
+}
+
+// Latency returns the filter delay in output samples per channel
+func (r *InterleavedResampler) Latency() int {
+	return r.channels[0].Latency()
+}
+
+// Reset clears every channel's filter history
+func (r *InterleavedResampler) Reset() {
+	for _, resampler := range r.channels {
+		resampler.Reset()
+	}
+}
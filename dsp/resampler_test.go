@@ -0,0 +1,238 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+var ratePairs = []struct{ in, out int }{
+	{16000, 48000},
+	{44100, 48000},
+	{48000, 16000},
+	{48000, 44100},
+}
+
+// resampleTone feeds a unit-amplitude sine through r in 10ms chunks and
+// returns the output with the filter warm-up trimmed off
+func resampleTone(r *Resampler, freq float64, seconds float64) []float32 {
+	chunk := r.InRate() / 100
+	total := int(seconds * float64(r.InRate()))
+	in := make([]float32, chunk)
+	out := make([]float32, r.MaxOutput(chunk))
+
+	var result []float32
+	for start := 0; start+chunk <= total; start += chunk {
+		for i := range in {
+			in[i] = float32(math.Sin(2 * math.Pi * freq * float64(start+i) / float64(r.InRate())))
+		}
+		n := r.Process(in, out)
+		result = append(result, out[:n]...)
+	}
+
+	// Skip twice the filter length so only the steady state is measured
+	skip := 2 * r.taps * r.up / r.down
+	return result[skip:]
+}
+
+// fitTone least-squares fits a sinusoid at freq and returns its amplitude
+// and the RMS of everything else (aliases, images and distortion)
+func fitTone(x []float32, freq float64, rate int) (amplitude, residual float64) {
+	var ss, sc, cc, xs, xc float64
+	w := 2 * math.Pi * freq / float64(rate)
+	for i, v := range x {
+		s, c := math.Sin(w*float64(i)), math.Cos(w*float64(i))
+		ss += s * s
+		sc += s * c
+		cc += c * c
+		xs += float64(v) * s
+		xc += float64(v) * c
+	}
+	det := ss*cc - sc*sc
+	a := (xs*cc - xc*sc) / det
+	b := (xc*ss - xs*sc) / det
+
+	var sum float64
+	for i, v := range x {
+		e := float64(v) - a*math.Sin(w*float64(i)) - b*math.Cos(w*float64(i))
+		sum += e * e
+	}
+	return math.Hypot(a, b), math.Sqrt(sum / float64(len(x)))
+}
+
+func rms(x []float32) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
+
+func toDB(x float64) float64 {
+	return 20 * math.Log10(x)
+}
+
+func TestResamplerPassbandRipple(t *testing.T) {
+	for _, pair := range ratePairs {
+		t.Run(fmt.Sprintf("%dTo%d", pair.in, pair.out), func(t *testing.T) {
+			nyquist := float64(min(pair.in, pair.out)) / 2
+			edge := nyquist * resamplerPassband
+
+			for _, fraction := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 1.0} {
+				freq := edge * fraction
+				r := NewResampler(pair.in, pair.out)
+				out := resampleTone(r, freq, 0.5)
+
+				amplitude, residual := fitTone(out, freq, pair.out)
+				if ripple := toDB(amplitude); math.Abs(ripple) > 0.01 {
+					t.Errorf("%.0f Hz: gain %.4f dB, want within ±0.01 dB", freq, ripple)
+				}
+				// Images and aliases of an in-band tone must be inaudible
+				if level := toDB(residual); level > -90 {
+					t.Errorf("%.0f Hz: spurious content at %.1f dB, want below -90 dB", freq, level)
+				}
+			}
+		})
+	}
+}
+
+func TestResamplerAliasRejection(t *testing.T) {
+	for _, pair := range ratePairs {
+		if pair.out >= pair.in {
+			continue // Only downsampling can alias
+		}
+		t.Run(fmt.Sprintf("%dTo%d", pair.in, pair.out), func(t *testing.T) {
+			// Tones between the output and input Nyquist frequencies
+			outNyquist, inNyquist := float64(pair.out)/2, float64(pair.in)/2
+			for _, freq := range []float64{outNyquist * 1.01, (outNyquist + inNyquist) / 2, inNyquist * 0.99} {
+				r := NewResampler(pair.in, pair.out)
+				out := resampleTone(r, freq, 0.5)
+
+				if level := toDB(rms(out) * math.Sqrt2); level > -90 {
+					t.Errorf("%.0f Hz aliased at %.1f dB, want below -90 dB", freq, level)
+				}
+			}
+		})
+	}
+}
+
+func TestResamplerOutputCount(t *testing.T) {
+	for _, pair := range ratePairs {
+		t.Run(fmt.Sprintf("%dTo%d", pair.in, pair.out), func(t *testing.T) {
+			r := NewResampler(pair.in, pair.out)
+			chunk := pair.in / 100
+			in := make([]float32, chunk)
+			out := make([]float32, r.MaxOutput(chunk))
+
+			// 10ms chunks at rates divisible by 100 map to exactly 10ms out
+			for i := 0; i < 50; i++ {
+				if n := r.Process(in, out); n != pair.out/100 {
+					t.Fatalf("chunk %d: produced %d samples, want %d", i, n, pair.out/100)
+				}
+			}
+		})
+	}
+}
+
+func TestResamplerLatency(t *testing.T) {
+	r := NewResampler(16000, 48000)
+
+	in := make([]float32, 1600)
+	in[0] = 1
+	out := make([]float32, r.MaxOutput(len(in)))
+	n := r.Process(in, out)
+
+	peak := 0
+	for i := 1; i < n; i++ {
+		if math.Abs(float64(out[i])) > math.Abs(float64(out[peak])) {
+			peak = i
+		}
+	}
+	if diff := peak - r.Latency(); diff < -1 || diff > 1 {
+		t.Errorf("impulse peak at %d, Latency() = %d", peak, r.Latency())
+	}
+}
+
+func TestResamplerReset(t *testing.T) {
+	r := NewResampler(44100, 48000)
+	noise := make([]float32, 441)
+	for i := range noise {
+		noise[i] = float32(math.Sin(float64(i)))
+	}
+	out := make([]float32, r.MaxOutput(len(noise)))
+	r.Process(noise, out)
+
+	r.Reset()
+	n := r.Process(make([]float32, 441), out)
+	for i := 0; i < n; i++ {
+		if out[i] != 0 {
+			t.Fatalf("sample %d = %v after Reset, want 0", i, out[i])
+		}
+	}
+}
+
+func TestInterleavedResampler(t *testing.T) {
+	const channels = 2
+	r := NewInterleavedResampler(44100, 48000, channels, 441)
+	mono := []*Resampler{NewResampler(44100, 48000), NewResampler(44100, 48000)}
+
+	in := make([]float32, 441*channels)
+	out := make([]float32, r.MaxOutput(len(in)))
+	monoIn := make([]float32, 441)
+	monoOut := make([]float32, mono[0].MaxOutput(441))
+
+	for block := 0; block < 10; block++ {
+		for i := 0; i < 441; i++ {
+			n := float64(block*441 + i)
+			in[i*channels] = float32(math.Sin(2 * math.Pi * 1000 * n / 44100))
+			in[i*channels+1] = float32(math.Sin(2 * math.Pi * 3000 * n / 44100))
+		}
+
+		written := r.Process(in, out)
+		if written != 480*channels {
+			t.Fatalf("block %d: wrote %d samples, want %d", block, written, 480*channels)
+		}
+
+		// Each channel must match an independent mono resampler exactly
+		for c := 0; c < channels; c++ {
+			for i := range monoIn {
+				monoIn[i] = in[i*channels+c]
+			}
+			n := mono[c].Process(monoIn, monoOut)
+			for i := 0; i < n; i++ {
+				if out[i*channels+c] != monoOut[i] {
+					t.Fatalf("block %d channel %d sample %d: %v, want %v", block, c, i, out[i*channels+c], monoOut[i])
+				}
+			}
+		}
+	}
+}
+
+func TestResamplerDoesNotAllocate(t *testing.T) {
+	mono := NewResampler(44100, 48000)
+	monoIn := make([]float32, 441)
+	monoOut := make([]float32, mono.MaxOutput(len(monoIn)))
+
+	stereo := NewInterleavedResampler(44100, 48000, 2, 441)
+	stereoIn := make([]float32, 441*2)
+	stereoOut := make([]float32, stereo.MaxOutput(len(stereoIn)))
+
+	if allocs := testing.AllocsPerRun(100, func() { mono.Process(monoIn, monoOut) }); allocs != 0 {
+		t.Errorf("Resampler.Process allocates %.1f times per call, want 0", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { stereo.Process(stereoIn, stereoOut) }); allocs != 0 {
+		t.Errorf("InterleavedResampler.Process allocates %.1f times per call, want 0", allocs)
+	}
+}
+
+func BenchmarkResampler44100To48000(b *testing.B) {
+	r := NewResampler(44100, 48000)
+	in := make([]float32, 441)
+	out := make([]float32, r.MaxOutput(len(in)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.Process(in, out)
+	}
+}
@@ -0,0 +1,382 @@
+package dsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+const (
+	// subtractionFFTSize is the analysis window: 10.7ms at 48kHz, fine
+	// enough to separate fan and HVAC tones from speech harmonics
+	subtractionFFTSize = 512
+	// subtractionHop is the distance between windows (75% overlap)
+	subtractionHop = subtractionFFTSize / 4
+	// subtractionFade is how long switching between the undelayed input and
+	// the analysis output crossfades
+	subtractionFade = 10 * time.Millisecond
+)
+
+// NoiseProfile is the average power spectrum of stationary background noise,
+// captured while nobody is talking
+type NoiseProfile struct {
+	SampleRate int       `json:"sample_rate"`
+	FFTSize    int       `json:"fft_size"`
+	Power      []float64 `json:"power"` // mean power of each bin from DC to Nyquist
+}
+
+// Save writes the profile to path as JSON, creating parent directories
+func (p *NoiseProfile) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create noise profile directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save noise profile: %v", err)
+	}
+	return nil
+}
+
+// LoadNoiseProfile reads a profile written by Save
+func LoadNoiseProfile(path string) (*NoiseProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read noise profile: %w", err)
+	}
+
+	var p NoiseProfile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid noise profile %s: %v", path, err)
+	}
+	if p.FFTSize <= 0 || len(p.Power) != p.FFTSize/2+1 {
+		return nil, fmt.Errorf("invalid noise profile %s: %d bins for FFT size %d", path, len(p.Power), p.FFTSize)
+	}
+	return &p, nil
+}
+
+// NoiseProfilePath returns where the noise profile of an input device is
+// stored, under the user's configuration directory
+func NoiseProfilePath(device string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	// Device names contain spaces, brackets and colons; keep a readable slug
+	words := strings.FieldsFunc(strings.ToLower(device), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	name := strings.Join(words, "-")
+	if name == "" {
+		name = "default"
+	}
+	return filepath.Join(dir, "clearvox", "noise-profiles", name+".json"), nil
+}
+
+// SubtractionConfig configures a NoiseSubtractor
+type SubtractionConfig struct {
+	// OverSubtraction scales the noise profile before it is subtracted;
+	// values above 1 remove more noise at the risk of warbling artefacts
+	OverSubtraction float64
+	// FloorDB is the most any frequency is turned down
+	FloorDB float64
+}
+
+// DefaultSubtractionConfig returns settings that remove fan noise without
+// hollowing out speech
+func DefaultSubtractionConfig() SubtractionConfig {
+	return SubtractionConfig{
+		OverSubtraction: 2,
+		FloorDB:         -20,
+	}
+}
+
+// learnJob is a pending request to capture a noise profile
+type learnJob struct {
+	hops int
+	done chan *NoiseProfile
+}
+
+// NoiseSubtractor is a Processor that removes a learned stationary noise
+// spectrum by spectral subtraction, complementing RNNoise on noise specific
+// to the room. Until a profile is loaded or learning starts it passes audio
+// through without delay; from then on it adds a fixed analysis delay. The
+// analysis keeps running while passing through, so the switch is a short
+// crossfade rather than a gap.
+type NoiseSubtractor struct {
+	sampleRate      int
+	channels        int
+	overSubtraction float64
+	floor           float64
+
+	enabled atomic.Bool
+	profile atomic.Pointer[NoiseProfile]
+	pending atomic.Pointer[learnJob] // requested capture, nil once finished or cancelled
+
+	fft      *FFT
+	window   []float64 // square-root Hann, used for analysis and synthesis
+	in       [][]float64
+	out      [][]float64
+	overlap  [][]float64
+	rover    int // position within the current hop
+	spectrum []complex128
+	mix      float32 // 0 = undelayed input, 1 = analysis output
+	fadeStep float32 // mix change per sample
+
+	// Learning state, owned by the processing goroutine
+	job        *learnJob
+	learnPower []float64
+	learnHops  int
+}
+
+var _ Processor = (*NoiseSubtractor)(nil)
+
+// NewNoiseSubtractor creates an enabled NoiseSubtractor without a profile for
+// interleaved audio with the given sample rate and channel count
+func NewNoiseSubtractor(sampleRate, channels int, cfg SubtractionConfig) *NoiseSubtractor {
+	s := &NoiseSubtractor{
+		sampleRate:      sampleRate,
+		overSubtraction: cfg.OverSubtraction,
+		floor:           math.Pow(10, cfg.FloorDB/20),
+		fft:             NewFFT(subtractionFFTSize),
+		window:          make([]float64, subtractionFFTSize),
+		spectrum:        make([]complex128, subtractionFFTSize),
+		learnPower:      make([]float64, subtractionFFTSize/2+1),
+		fadeStep:        float32(1 / max(1, subtractionFade.Seconds()*float64(sampleRate))),
+	}
+	for i := range s.window {
+		s.window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/subtractionFFTSize))
+	}
+	s.enabled.Store(true)
+	s.SetChannels(channels)
+	return s
+}
+
+// SetChannels changes the number of interleaved channels per frame. Must not
+// be called while Process is running.
+func (s *NoiseSubtractor) SetChannels(channels int) {
+	s.channels = max(1, channels)
+	s.in = make([][]float64, s.channels)
+	s.out = make([][]float64, s.channels)
+	s.overlap = make([][]float64, s.channels)
+	for ch := range s.in {
+		s.in[ch] = make([]float64, subtractionFFTSize)
+		s.out[ch] = make([]float64, subtractionHop)
+		s.overlap[ch] = make([]float64, subtractionFFTSize)
+	}
+	s.Reset()
+}
+
+// Process removes the noise profile from one interleaved frame in place
+func (s *NoiseSubtractor) Process(frame []float32) error {
+	if len(frame)%s.channels != 0 {
+		return fmt.Errorf("noise subtractor expects a multiple of %d samples, got %d", s.channels, len(frame))
+	}
+
+	target := float32(0)
+	if s.delaying() {
+		target = 1
+	}
+
+	for i := 0; i < len(frame); i += s.channels {
+		if s.mix < target {
+			s.mix = min(target, s.mix+s.fadeStep)
+		} else if s.mix > target {
+			s.mix = max(target, s.mix-s.fadeStep)
+		}
+		for ch := 0; ch < s.channels; ch++ {
+			sample := frame[i+ch]
+			s.in[ch][subtractionFFTSize-subtractionHop+s.rover] = float64(sample)
+			frame[i+ch] = s.mix*float32(s.out[ch][s.rover]) + (1-s.mix)*sample
+		}
+
+		s.rover++
+		if s.rover == subtractionHop {
+			s.processHop()
+			s.rover = 0
+		}
+	}
+	return nil
+}
+
+// processHop analyses the latest window of every channel, applies the
+// suppression gains and overlap-adds the result
+func (s *NoiseSubtractor) processHop() {
+	if job := s.pending.Load(); job != s.job {
+		// A new capture was requested, or the current one cancelled
+		s.job = job
+		clear(s.learnPower)
+		s.learnHops = 0
+	}
+
+	profile := s.profile.Load()
+	subtract := profile != nil && s.enabled.Load()
+	transform := subtract || s.job != nil
+
+	for ch := range s.in {
+		in := s.in[ch]
+		for i, sample := range in {
+			s.spectrum[i] = complex(sample*s.window[i], 0)
+		}
+
+		if transform {
+			s.fft.Forward(s.spectrum)
+			if s.job != nil {
+				for k := range s.learnPower {
+					s.learnPower[k] += power(s.spectrum[k]) / float64(s.channels)
+				}
+			}
+			if subtract {
+				s.applyGains(profile)
+			}
+			s.fft.Inverse(s.spectrum)
+		}
+
+		// Analysis and synthesis windows multiply to a Hann window, and Hann
+		// windows at 75% overlap sum to 2
+		overlap := s.overlap[ch]
+		for i := range overlap {
+			overlap[i] += real(s.spectrum[i]) * s.window[i] * 0.5
+		}
+		copy(s.out[ch], overlap[:subtractionHop])
+		copy(overlap, overlap[subtractionHop:])
+		clear(overlap[subtractionFFTSize-subtractionHop:])
+		copy(in, in[subtractionHop:])
+	}
+
+	if s.job != nil {
+		s.learnHops++
+		if s.learnHops >= s.job.hops {
+			s.finishLearning()
+		}
+	}
+}
+
+// applyGains scales every bin of the spectrum by its power subtraction gain
+func (s *NoiseSubtractor) applyGains(profile *NoiseProfile) {
+	for k, noise := range profile.Power {
+		p := power(s.spectrum[k])
+		gain := 1.0
+		if p > 0 {
+			gain = max(s.floor, math.Sqrt(max(0, 1-s.overSubtraction*noise/p)))
+		}
+		s.spectrum[k] *= complex(gain, 0)
+		if k > 0 && k < subtractionFFTSize/2 {
+			s.spectrum[subtractionFFTSize-k] *= complex(gain, 0)
+		}
+	}
+}
+
+// finishLearning turns the accumulated spectrum into the active profile
+func (s *NoiseSubtractor) finishLearning() {
+	profile := &NoiseProfile{
+		SampleRate: s.sampleRate,
+		FFTSize:    subtractionFFTSize,
+		Power:      make([]float64, len(s.learnPower)),
+	}
+	for k, sum := range s.learnPower {
+		profile.Power[k] = sum / float64(s.learnHops)
+	}
+	s.profile.Store(profile)
+
+	s.job.done <- profile
+	s.pending.CompareAndSwap(s.job, nil) // Unless a new capture replaced it
+	s.job = nil
+}
+
+// power returns the squared magnitude of a bin
+func power(bin complex128) float64 {
+	return real(bin)*real(bin) + imag(bin)*imag(bin)
+}
+
+// Learn captures a new noise profile from the next duration of audio, which
+// should contain only background noise. The profile is applied as soon as
+// it is complete and also sent on the returned channel. A capture abandoned
+// by CancelLearn or a later Learn call never sends, and neither does one
+// that is waiting when audio stops being processed.
+func (s *NoiseSubtractor) Learn(duration time.Duration) <-chan *NoiseProfile {
+	samples := int(duration.Seconds() * float64(s.sampleRate))
+	job := &learnJob{
+		hops: max(1, samples/subtractionHop),
+		done: make(chan *NoiseProfile, 1),
+	}
+	s.pending.Store(job)
+	return job.done
+}
+
+// CancelLearn abandons an unfinished capture, keeping the current profile
+func (s *NoiseSubtractor) CancelLearn() {
+	s.pending.Store(nil)
+}
+
+// Learning reports whether a noise profile is being captured
+func (s *NoiseSubtractor) Learning() bool {
+	return s.pending.Load() != nil
+}
+
+// delaying reports whether audio needs to go through the analysis: when
+// there is a profile to subtract or one is being learned
+func (s *NoiseSubtractor) delaying() bool {
+	return s.profile.Load() != nil || s.pending.Load() != nil
+}
+
+// SetProfile replaces the noise profile; nil removes it
+func (s *NoiseSubtractor) SetProfile(p *NoiseProfile) error {
+	if p != nil && (p.SampleRate != s.sampleRate || p.FFTSize != subtractionFFTSize || len(p.Power) != subtractionFFTSize/2+1) {
+		return fmt.Errorf("noise profile was learned at %d Hz with FFT size %d, expected %d Hz and %d", p.SampleRate, p.FFTSize, s.sampleRate, subtractionFFTSize)
+	}
+	s.profile.Store(p)
+	return nil
+}
+
+// Profile returns the active noise profile, or nil if none was learned or
+// loaded. The profile must not be modified.
+func (s *NoiseSubtractor) Profile() *NoiseProfile {
+	return s.profile.Load()
+}
+
+// Reset clears the analysis buffers; the profile is kept and an unfinished
+// capture starts over
+func (s *NoiseSubtractor) Reset() {
+	for ch := range s.in {
+		clear(s.in[ch])
+		clear(s.out[ch])
+		clear(s.overlap[ch])
+	}
+	s.rover = 0
+	// Fade in from the undelayed input while the cleared analysis refills
+	s.mix = 0
+	clear(s.learnPower)
+	s.learnHops = 0
+}
+
+// Latency returns the analysis delay in samples per channel: a sample is
+// output once every window overlapping it has been processed. It is 0 while
+// there is no profile and nothing is being learned, and changes when either
+// starts.
+func (s *NoiseSubtractor) Latency() int {
+	if !s.delaying() {
+		return 0
+	}
+	return subtractionFFTSize
+}
+
+// SetEnabled turns subtraction on or off; while there is a profile the
+// audio stays delayed either way, so toggling doesn't click
+func (s *NoiseSubtractor) SetEnabled(enabled bool) {
+	s.enabled.Store(enabled)
+}
+
+// Enabled reports whether the profile is subtracted
+func (s *NoiseSubtractor) Enabled() bool {
+	return s.enabled.Load()
+}
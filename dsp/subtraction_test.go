@@ -0,0 +1,271 @@
+package dsp
+
+import (
+	"errors"
+	"io/fs"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// roomNoise returns stationary noise like a server fan: low-pass filtered
+// noise plus a 120 Hz motor hum and a 1 kHz whine
+func roomNoise(seed int64, samples int) []float32 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float32, samples)
+	var lowpass float64
+	for i := range out {
+		lowpass = 0.8*lowpass + 0.2*rng.NormFloat64()
+		t := float64(i) / testRate
+		out[i] = float32(0.05*lowpass + 0.02*math.Sin(2*math.Pi*120*t) + 0.01*math.Sin(2*math.Pi*1000*t))
+	}
+	return out
+}
+
+// processInFrames runs p over signal in 10ms frames
+func processInFrames(t *testing.T, p Processor, signal []float32) {
+	t.Helper()
+	for start := 0; start+480 <= len(signal); start += 480 {
+		if err := p.Process(signal[start : start+480]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNoiseSubtractorTransparentWithoutProfile(t *testing.T) {
+	s := NewNoiseSubtractor(testRate, 2, DefaultSubtractionConfig())
+	if latency := s.Latency(); latency != 0 {
+		t.Errorf("Latency() = %d without a profile, want 0", latency)
+	}
+
+	input := speechLike(1, 20*960, 0.5, 2.5, 0)
+	output := append([]float32(nil), input...)
+	for start := 0; start < 10*960; start += 960 {
+		if err := s.Process(output[start : start+960]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 10*960; i++ {
+		if output[i] != input[i] {
+			t.Fatalf("sample %d = %v without a profile, want it unchanged (%v)", i, output[i], input[i])
+		}
+	}
+
+	// With a profile of silence the audio is only delayed, once the switch has
+	// crossfaded from the input to the analysis output
+	profile := &NoiseProfile{SampleRate: testRate, FFTSize: subtractionFFTSize, Power: make([]float64, subtractionFFTSize/2+1)}
+	if err := s.SetProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	if latency := s.Latency(); latency != subtractionFFTSize {
+		t.Errorf("Latency() = %d with a profile, want %d", latency, subtractionFFTSize)
+	}
+	for start := 10 * 960; start < len(output); start += 960 {
+		if err := s.Process(output[start : start+960]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	delay := s.Latency() * 2
+	fade := 2 * int(subtractionFade.Seconds()*testRate)
+	for i := 10*960 + fade; i < len(output); i++ {
+		if want := input[i-delay]; math.Abs(float64(output[i]-want)) > 1e-5 {
+			t.Fatalf("sample %d = %v, want %v", i, output[i], want)
+		}
+	}
+}
+
+func TestNoiseSubtractorCancelLearn(t *testing.T) {
+	s := NewNoiseSubtractor(testRate, 1, DefaultSubtractionConfig())
+
+	done := s.Learn(time.Second)
+	if s.Latency() != subtractionFFTSize {
+		t.Errorf("Latency() = %d while learning, want %d", s.Latency(), subtractionFFTSize)
+	}
+	processInFrames(t, s, roomNoise(1, testRate/2))
+	s.CancelLearn()
+	if s.Learning() {
+		t.Error("Learning() = true after CancelLearn")
+	}
+
+	input := roomNoise(2, testRate)
+	output := append([]float32(nil), input...)
+	processInFrames(t, s, output)
+	select {
+	case <-done:
+		t.Error("cancelled capture delivered a profile")
+	default:
+	}
+	if s.Profile() != nil {
+		t.Error("cancelled capture set a profile")
+	}
+
+	// Back to pass-through once nothing is learned and the switch has faded
+	if s.Latency() != 0 {
+		t.Errorf("Latency() = %d after cancelling, want 0", s.Latency())
+	}
+	for i := int(subtractionFade.Seconds() * testRate); i < len(output); i++ {
+		if output[i] != input[i] {
+			t.Fatalf("sample %d = %v after cancelling, want it unchanged (%v)", i, output[i], input[i])
+		}
+	}
+
+	// A later capture still works
+	done = s.Learn(100 * time.Millisecond)
+	processInFrames(t, s, roomNoise(3, testRate/2))
+	select {
+	case <-done:
+	default:
+		t.Error("no profile delivered after cancelling and learning again")
+	}
+}
+
+func TestNoiseSubtractorRemovesLearnedNoise(t *testing.T) {
+	s := NewNoiseSubtractor(testRate, 1, DefaultSubtractionConfig())
+
+	done := s.Learn(time.Second)
+	if !s.Learning() {
+		t.Error("Learning() = false right after Learn")
+	}
+	processInFrames(t, s, roomNoise(1, testRate+4800))
+
+	var profile *NoiseProfile
+	select {
+	case profile = <-done:
+	default:
+		t.Fatal("no profile delivered after learning for the requested duration")
+	}
+	if s.Learning() || s.Profile() != profile {
+		t.Fatalf("Learning() = %v, Profile() = %p after learning, want false and %p", s.Learning(), s.Profile(), profile)
+	}
+
+	t.Run("NoiseOnly", func(t *testing.T) {
+		noise := roomNoise(2, testRate)
+		output := append([]float32(nil), noise...)
+		processInFrames(t, s, output)
+
+		half := len(noise) / 2
+		if reduction := toDB(rms(noise[half:]) / rms(output[half:])); reduction < 10 {
+			t.Errorf("noise reduced by %.1f dB, want at least 10 dB", reduction)
+		}
+	})
+
+	t.Run("SpeechKept", func(t *testing.T) {
+		noisy := roomNoise(3, testRate)
+		for i := range noisy {
+			noisy[i] += float32(0.3 * math.Sin(2*math.Pi*440*float64(i)/testRate))
+		}
+		processInFrames(t, s, noisy)
+
+		amplitude, _ := fitTone(noisy[testRate/2:], 440, testRate)
+		if change := toDB(amplitude / 0.3); math.Abs(change) > 1 {
+			t.Errorf("speech level changed by %+.1f dB, want within 1 dB", change)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		s.SetEnabled(false)
+		defer s.SetEnabled(true)
+
+		noise := roomNoise(4, testRate/2)
+		output := append([]float32(nil), noise...)
+		processInFrames(t, s, output)
+		delay := s.Latency()
+		for i := 2 * delay; i < len(output); i++ {
+			if math.Abs(float64(output[i]-noise[i-delay])) > 1e-5 {
+				t.Fatalf("sample %d = %v while disabled, want the delayed input %v", i, output[i], noise[i-delay])
+			}
+		}
+	})
+}
+
+func TestNoiseProfileFiles(t *testing.T) {
+	profile := &NoiseProfile{SampleRate: testRate, FFTSize: subtractionFFTSize, Power: make([]float64, subtractionFFTSize/2+1)}
+	for k := range profile.Power {
+		profile.Power[k] = 1 / float64(k+1)
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "profiles", "mic.json")
+		if err := profile.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadNoiseProfile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.SampleRate != profile.SampleRate || loaded.FFTSize != profile.FFTSize || len(loaded.Power) != len(profile.Power) {
+			t.Fatalf("loaded %d Hz, FFT %d, %d bins", loaded.SampleRate, loaded.FFTSize, len(loaded.Power))
+		}
+		for k := range profile.Power {
+			if loaded.Power[k] != profile.Power[k] {
+				t.Fatalf("bin %d = %v, want %v", k, loaded.Power[k], profile.Power[k])
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		dir := t.TempDir()
+		for name, content := range map[string]string{
+			"garbage.json":   "not json",
+			"truncated.json": `{"sample_rate": 48000, "fft_size": 512, "power": [1, 2, 3]}`,
+		} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadNoiseProfile(path); err == nil {
+				t.Errorf("LoadNoiseProfile(%s) succeeded, want error", name)
+			}
+		}
+		if _, err := LoadNoiseProfile(filepath.Join(dir, "missing.json")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("LoadNoiseProfile of a missing file error = %v, want fs.ErrNotExist", err)
+		}
+	})
+
+	t.Run("WrongSampleRate", func(t *testing.T) {
+		s := NewNoiseSubtractor(44100, 1, DefaultSubtractionConfig())
+		if err := s.SetProfile(profile); err == nil {
+			t.Error("SetProfile with a 48 kHz profile on a 44.1 kHz subtractor succeeded, want error")
+		}
+		if err := s.SetProfile(nil); err != nil {
+			t.Errorf("SetProfile(nil) error = %v", err)
+		}
+	})
+
+	t.Run("PathPerDevice", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		tests := []struct {
+			device string
+			want   string
+		}{
+			{"MacBook Pro Microphone", "macbook-pro-microphone.json"},
+			{"Headset (USB Audio) #2", "headset-usb-audio-2.json"},
+			{"", "default.json"},
+		}
+		for _, tt := range tests {
+			path, err := NoiseProfilePath(tt.device)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filepath.Base(path); got != tt.want {
+				t.Errorf("NoiseProfilePath(%q) file = %s, want %s", tt.device, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestNoiseSubtractorDoesNotAllocate(t *testing.T) {
+	s := NewNoiseSubtractor(testRate, 2, DefaultSubtractionConfig())
+	profile := &NoiseProfile{SampleRate: testRate, FFTSize: subtractionFFTSize, Power: make([]float64, subtractionFFTSize/2+1)}
+	if err := s.SetProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	frame := speechLike(1, 960, 0.5, 2.5, 0)
+	if allocs := testing.AllocsPerRun(100, func() { _ = s.Process(frame) }); allocs != 0 {
+		t.Errorf("NoiseSubtractor allocates %.1f times per frame, want 0", allocs)
+	}
+}
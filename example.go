@@ -2,15 +2,20 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/errakhaoui/noise-canceling/dsp"
 	"github.com/errakhaoui/noise-canceling/input"
+	"github.com/errakhaoui/noise-canceling/latency"
 	"github.com/errakhaoui/noise-canceling/noise_canceller"
 	"github.com/errakhaoui/noise-canceling/output"
 	"github.com/gordonklaus/portaudio"
@@ -21,6 +26,42 @@ func main() {
 	listDevices := flag.Bool("list-devices", false, "List all available output devices and exit")
 	deviceName := flag.String("device", "", "Output device name - use virtual audio device for ClearVox Virtual Mic (e.g., 'BlackHole 2ch')")
 	monitorDevice := flag.String("monitor-device", "", "Additional output device for monitoring (e.g., 'Headphones')")
+	modelPath := flag.String("model", "", "Path to a custom RNNoise model file (default: built-in model)")
+	strength := flag.Float64("strength", 100, "Noise suppression strength in percent (0 = original audio, 100 = fully denoised)")
+	crossfade := flag.Duration("crossfade", noise_canceller.DefaultCrossfade, "How long toggling noise cancellation fades between denoised and original audio (0 switches instantly)")
+	softClip := flag.Bool("soft-clip", false, "Smoothly round off peaks near full scale instead of hard clipping")
+	gateThreshold := flag.Float64("gate-threshold", 0, "Voice probability (0-1) below which audio is gated between sentences (0 disables the gate)")
+	channels := flag.Int("channels", 1, "Number of input channels to capture and denoise (e.g., 2 for stereo interfaces)")
+	channelMode := flag.String("channel-mode", "linked", "How multi-channel audio is denoised: 'linked' keeps the stereo image stable, 'independent' denoises each channel separately")
+	aecReference := flag.String("aec-reference", "", "Input device capturing what the speakers play (e.g., a loopback device or 'Stereo Mix') - enables echo cancellation")
+	aecTail := flag.Duration("aec-tail", 80*time.Millisecond, "Longest room echo the echo canceller models")
+	humRemoval := flag.Bool("hum", false, "Detect 50/60 Hz mains hum and notch out its fundamental and harmonics")
+	humHarmonics := flag.Int("hum-harmonics", dsp.DefaultHumConfig().Harmonics, "Number of hum harmonics removed, including the fundamental")
+	clickSuppression := flag.Bool("click-suppression", false, "Duck keyboard and mouse clicks that RNNoise lets through; speech is left alone")
+	clickSensitivity := flag.Float64("click-sensitivity", float64(dsp.DefaultClickConfig().Sensitivity), "How small a transient is treated as a click, from 0 (only the loudest) to 1")
+	comfortNoise := flag.Bool("comfort-noise", false, "Fill pauses with soft noise matching the room so listeners don't think the call dropped")
+	comfortLevel := flag.Float64("comfort-level", float64(dsp.DefaultComfortNoiseConfig().LevelDB), "Comfort noise level in dB relative to the measured background noise")
+	learn := flag.Duration("learn", 0, "Learn the background noise for this long at startup (stay silent), e.g. '3s'; the profile is saved for the input device and subtracted alongside RNNoise")
+	noiseProfile := flag.Bool("noise-profile", true, "Subtract the input device's saved noise profile, if one was learned")
+	highPass := flag.Float64("highpass", dsp.DefaultHighPassCutoff, "High-pass cutoff in Hz applied before denoising to remove DC offset and rumble (0 disables)")
+	eqPreset := flag.String("eq-preset", "flat", "EQ preset applied after denoising: "+strings.Join(dsp.EQPresets(), ", "))
+	eqConfig := flag.String("eq-config", "", "Path to a JSON EQ config file, used instead of -eq-preset")
+	var eqBands eqBandFlags
+	flag.Var(&eqBands, "eq-band", "Extra EQ band as type:frequency:q[:gain_db], e.g. 'peaking:3000:1:4' (repeatable)")
+	agcEnabled := flag.Bool("agc", false, "Enable automatic gain control to even out quiet and loud speakers")
+	agcTarget := flag.Float64("agc-target", -18, "AGC target speech level in dBFS")
+	agcMaxGain := flag.Float64("agc-max-gain", 18, "Maximum AGC boost in dB")
+	agcAttack := flag.Duration("agc-attack", 50*time.Millisecond, "How quickly the AGC turns loud speech down")
+	agcRelease := flag.Duration("agc-release", 800*time.Millisecond, "How quickly the AGC turns quiet speech up")
+	compressorEnabled := flag.Bool("compressor", false, "Enable the dynamics compressor to even out loud syllables")
+	compThreshold := flag.Float64("comp-threshold", -20, "Compressor threshold in dBFS")
+	compRatio := flag.Float64("comp-ratio", 3, "Compressor ratio (e.g., 3 for 3:1)")
+	compKnee := flag.Float64("comp-knee", 6, "Compressor soft knee width in dB (0 for a hard knee)")
+	compAttack := flag.Duration("comp-attack", 5*time.Millisecond, "How quickly the compressor reacts to peaks")
+	compRelease := flag.Duration("comp-release", 120*time.Millisecond, "How quickly the compressor lets go after peaks")
+	compMakeup := flag.Float64("comp-makeup", 3, "Makeup gain in dB applied after compression")
+	limiterEnabled := flag.Bool("limiter", true, "Enable the brickwall limiter that stops the output from clipping")
+	limiterCeiling := flag.Float64("limiter-ceiling", -1, "Highest output peak level in dBFS")
 	flag.Parse()
 
 	// If list-devices flag is set, print devices and exit
@@ -30,11 +71,63 @@ func main() {
 	}
 
 	log.Println("Start noise cancellation ...")
+	log.Printf("Noise suppression engine: %s", noise_canceller.EngineName)
 	log.Println("Press 't' + Enter to toggle noise cancellation ON/OFF")
 	log.Printf("Noise cancellation: ENABLED")
 
+	if *modelPath != "" {
+		if err := noise_canceller.LoadModel(*modelPath); err != nil {
+			log.Fatalf("Error loading model: %v", err)
+		}
+		log.Printf("Using RNNoise model: %s", *modelPath)
+	}
+
+	noise_canceller.SetStrength(float32(*strength / 100))
+	log.Printf("Suppression strength: %.0f%%", noise_canceller.Strength()*100)
+
+	noise_canceller.SetCrossfade(*crossfade)
+	noise_canceller.SetSoftClip(*softClip)
+
+	noise_canceller.SetGateThreshold(float32(*gateThreshold))
+	if *gateThreshold > 0 {
+		log.Printf("Noise gate: threshold %.2f", *gateThreshold)
+	}
+
+	mode, err := noise_canceller.ParseChannelMode(*channelMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := noise_canceller.SetChannels(*channels, mode); err != nil {
+		log.Fatalf("Error setting up %d channels: %v", *channels, err)
+	}
+	if *channels > 1 {
+		log.Printf("Channels: %d (%s)", *channels, mode)
+	}
+
 	// Initialize microphone input
-	input.StartMicAcquisition()
+	inputDevice, err := portaudio.DefaultInputDevice()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := input.StartMicAcquisitionWithChannels(inputDevice, *channels); err != nil {
+		log.Fatal(err)
+	}
+
+	// Capture the echo reference alongside the microphone
+	var aec *dsp.AEC
+	if *aecReference != "" {
+		device, err := input.FindInputDeviceByName(*aecReference)
+		if err != nil {
+			log.Fatalf("Error finding echo reference device '%s': %v", *aecReference, err)
+		}
+		if err := input.StartReferenceCapture(device); err != nil {
+			log.Fatal(err)
+		}
+		aecConfig := dsp.DefaultAECConfig()
+		aecConfig.TailLength = *aecTail
+		aec = dsp.NewAEC(input.SampleRate, *channels, aecConfig)
+		log.Printf("Echo cancellation: reference %s, %v tail", device.Name, *aecTail)
+	}
 
 	// Initialize output device(s)
 	var devices []*portaudio.DeviceInfo
@@ -58,10 +151,129 @@ func main() {
 	}
 
 	// Start output streams
-	if err := output.StartOutputStreamToDevices(devices); err != nil {
+	if err := output.StartOutputStreamToDevicesWithChannels(devices, *channels); err != nil {
+		log.Fatal(err)
+	}
+
+	// Processing stages run in order; add filters before or after RNNoise here
+	denoiser := noise_canceller.DefaultStage()
+	agcConfig := dsp.DefaultAGCConfig()
+	agcConfig.TargetDB = float32(*agcTarget)
+	agcConfig.MaxGainDB = float32(*agcMaxGain)
+	agcConfig.Attack = *agcAttack
+	agcConfig.Release = *agcRelease
+	agc := dsp.NewAGC(input.SampleRate, *channels, agcConfig, denoiser)
+	agc.SetEnabled(*agcEnabled)
+	if *agcEnabled {
+		log.Printf("AGC: target %.0f dBFS, max gain %.0f dB", *agcTarget, *agcMaxGain)
+	}
+
+	highPassFilter := dsp.NewHighPass(input.SampleRate, *channels, *highPass)
+	highPassFilter.SetEnabled(*highPass > 0)
+	if *highPass > 0 {
+		log.Printf("High-pass filter: %.0f Hz", *highPass)
+	}
+
+	humConfig := dsp.DefaultHumConfig()
+	humConfig.Harmonics = *humHarmonics
+	humRemover := dsp.NewHumRemover(input.SampleRate, *channels, humConfig)
+	humRemover.SetEnabled(*humRemoval)
+	if *humRemoval {
+		log.Printf("Hum removal: %d harmonics", humRemover.Harmonics())
+	}
+
+	// Clicks are judged against RNNoise's voice probability to spare plosives
+	clickConfig := dsp.DefaultClickConfig()
+	clickConfig.Sensitivity = float32(*clickSensitivity)
+	clickSuppressor := dsp.NewClickSuppressor(input.SampleRate, *channels, clickConfig, denoiser)
+	clickSuppressor.SetEnabled(*clickSuppression)
+	if *clickSuppression {
+		log.Printf("Click suppression: sensitivity %.2f", clickSuppressor.Sensitivity())
+	}
+
+	// The background is measured before RNNoise removes it, then imitated in
+	// the pauses RNNoise reports
+	noiseEstimator := dsp.NewNoiseEstimator(input.SampleRate, *channels)
+	comfortConfig := dsp.DefaultComfortNoiseConfig()
+	comfortConfig.LevelDB = float32(*comfortLevel)
+	comfort := dsp.NewComfortNoise(input.SampleRate, *channels, comfortConfig, noiseEstimator, denoiser)
+	comfort.SetEnabled(*comfortNoise)
+	if *comfortNoise {
+		log.Printf("Comfort noise: %+.0f dB relative to the background", comfort.LevelDB())
+	}
+
+	// A config file names its own preset, so it replaces -eq-preset rather
+	// than stacking on top of it
+	var bands []dsp.EQBand
+	if *eqConfig != "" {
+		if flagSet("eq-preset") {
+			log.Fatal("Use either -eq-preset or -eq-config; set \"preset\" in the config file to combine them")
+		}
+		if bands, err = dsp.LoadEQConfig(*eqConfig); err != nil {
+			log.Fatal(err)
+		}
+	} else if bands, err = dsp.EQPreset(*eqPreset); err != nil {
 		log.Fatal(err)
 	}
+	bands = append(bands, eqBands...)
+	eq, err := dsp.NewEQ(input.SampleRate, *channels, bands)
+	if err != nil {
+		log.Fatalf("Error configuring EQ: %v", err)
+	}
+	for _, band := range bands {
+		log.Printf("EQ band: %s %.0f Hz, Q %.2f, %+.1f dB", band.Type, band.Frequency, band.Q, band.GainDB)
+	}
 
+	compressor := dsp.NewCompressor(input.SampleRate, *channels, dsp.CompressorConfig{
+		ThresholdDB: float32(*compThreshold),
+		Ratio:       float32(*compRatio),
+		KneeDB:      float32(*compKnee),
+		Attack:      *compAttack,
+		Release:     *compRelease,
+		MakeupDB:    float32(*compMakeup),
+	})
+	compressor.SetEnabled(*compressorEnabled)
+	if *compressorEnabled {
+		log.Printf("Compressor: %.0f dBFS threshold, %.1f:1, %+.0f dB makeup", *compThreshold, *compRatio, *compMakeup)
+	}
+
+	// The limiter must stay last so nothing after it can push peaks over
+	limiterConfig := dsp.DefaultLimiterConfig()
+	limiterConfig.CeilingDB = float32(*limiterCeiling)
+	limiter := dsp.NewLimiter(input.SampleRate, *channels, limiterConfig)
+	limiter.SetEnabled(*limiterEnabled)
+	if *limiterEnabled {
+		log.Printf("Limiter: ceiling %.1f dBFS", limiter.CeilingDB())
+	}
+
+	pipeline := dsp.NewChain(highPassFilter, humRemover, noiseEstimator, denoiser, clickSuppressor, comfort, eq, agc, compressor, limiter)
+
+	// Stationary room noise learned earlier is removed before RNNoise, but
+	// after the noise estimator so comfort noise matches the real room
+	if *noiseProfile || *learn > 0 {
+		subtractor := dsp.NewNoiseSubtractor(input.SampleRate, *channels, dsp.DefaultSubtractionConfig())
+		if *noiseProfile {
+			loadNoiseProfile(subtractor, inputDevice.Name)
+		}
+		if *learn > 0 {
+			log.Printf("Learning background noise for %v - stay silent...", *learn)
+			go saveNoiseProfile(subtractor.Learn(*learn), inputDevice.Name)
+		}
+		pipeline.Insert(3, subtractor)
+	}
+	if aec != nil {
+		// Echo is removed from the raw microphone, before anything else
+		pipeline.Insert(0, aec)
+	}
+
+	streams := latency.Streams{
+		Input:      input.Latency(),
+		Frame:      input.FrameDuration,
+		Output:     output.Latency(),
+		SampleRate: input.SampleRate,
+	}
+	report := latency.Measure(streams, pipeline)
+	log.Printf("Latency: %v", report)
 	log.Println("Ready! Audio processing started.")
 
 	// Set up signal handler for graceful shutdown
@@ -71,6 +283,7 @@ func main() {
 		<-sigChan
 		log.Println("\nShutting down...")
 		input.Close()
+		input.CloseReference()
 		output.Close()
 		input.Terminate()
 		output.Terminate()
@@ -81,12 +294,67 @@ func main() {
 	// Start keyboard listener in a separate goroutine
 	go keyboardListener()
 
+	// Warn when the output clips so the user can lower their input gain
+	go clipMonitor()
+
+	// Log the latency again when a stage changes its delay
+	go latencyMonitor(streams, pipeline, report.Total())
+
+	if aec != nil {
+		go echoMonitor(aec)
+	}
+	if *humRemoval {
+		go humMonitor(humRemover)
+	}
+	if *clickSuppression {
+		go clickMonitor(clickSuppressor)
+	}
+
 	for {
 		// Read audio from the input stream
 		input.ReadStream()
-		noise_canceller.Execute(input.InputBuffer)
-		output.ReadStream(input.InputBuffer)
+		if aec != nil {
+			input.ReadReference()
+			if err := aec.SetReference(input.ReferenceBufferFloat32); err != nil {
+				log.Printf("Error setting echo reference: %v", err)
+			}
+		}
+		if err := pipeline.Process(input.InputBufferFloat32); err != nil {
+			log.Printf("Error processing audio: %v", err)
+		}
+		output.ReadStreamFloat32(input.InputBufferFloat32)
+	}
+}
+
+// eqBandFlags collects repeated -eq-band flags
+type eqBandFlags []dsp.EQBand
+
+func (f *eqBandFlags) String() string {
+	specs := make([]string, len(*f))
+	for i, band := range *f {
+		specs[i] = fmt.Sprintf("%s:%g:%g:%g", band.Type, band.Frequency, band.Q, band.GainDB)
+	}
+	return strings.Join(specs, ",")
+}
+
+func (f *eqBandFlags) Set(spec string) error {
+	band, err := dsp.ParseEQBand(spec)
+	if err != nil {
+		return err
 	}
+	*f = append(*f, band)
+	return nil
+}
+
+// flagSet reports whether the named flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 func keyboardListener() {
@@ -105,3 +373,113 @@ func keyboardListener() {
 		}
 	}
 }
+
+func clipMonitor() {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	lastCount := noise_canceller.ClipCount()
+	for range ticker.C {
+		count := noise_canceller.ClipCount()
+		if count > lastCount {
+			log.Printf("Warning: %d samples clipped in the last 2s - lower your input gain", count-lastCount)
+		}
+		lastCount = count
+	}
+}
+
+// loadNoiseProfile applies the saved noise profile of an input device
+func loadNoiseProfile(subtractor *dsp.NoiseSubtractor, device string) {
+	path, err := dsp.NoiseProfilePath(device)
+	if err != nil {
+		log.Printf("Error locating noise profile: %v", err)
+		return
+	}
+	profile, err := dsp.LoadNoiseProfile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return // Never learned for this device
+	}
+	if err != nil {
+		log.Printf("Error loading noise profile: %v", err)
+		return
+	}
+	if err := subtractor.SetProfile(profile); err != nil {
+		log.Printf("Error applying noise profile: %v", err)
+		return
+	}
+	log.Printf("Noise profile: %s", path)
+}
+
+// saveNoiseProfile stores a newly learned noise profile for the input device
+func saveNoiseProfile(learned <-chan *dsp.NoiseProfile, device string) {
+	profile := <-learned
+	path, err := dsp.NoiseProfilePath(device)
+	if err == nil {
+		err = profile.Save(path)
+	}
+	if err != nil {
+		log.Printf("Error saving noise profile: %v", err)
+		return
+	}
+	log.Printf("Noise learned and saved to %s", path)
+}
+
+// humMonitor logs when mains hum is found or lost
+func humMonitor(hum *dsp.HumRemover) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	detected := false
+	for range ticker.C {
+		frequency := hum.Frequency()
+		if frequency > 0 && !detected {
+			log.Printf("Hum detected at %.2f Hz, removing it", frequency)
+		} else if frequency == 0 && detected {
+			log.Println("Hum gone")
+		}
+		detected = frequency > 0
+	}
+}
+
+// clickMonitor logs how many clicks were suppressed, at most every 10s
+func clickMonitor(clicks *dsp.ClickSuppressor) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	lastCount := clicks.Clicks()
+	for range ticker.C {
+		count := clicks.Clicks()
+		if count > lastCount {
+			log.Printf("Suppressed %d clicks in the last 10s", count-lastCount)
+		}
+		lastCount = count
+	}
+}
+
+// latencyMonitor logs the latency whenever it changes from last, such as when
+// a noise profile is learned
+func latencyMonitor(streams latency.Streams, pipeline *dsp.Chain, last time.Duration) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if report := latency.Measure(streams, pipeline); report.Total() != last {
+			log.Printf("Latency: %v", report)
+			last = report.Total()
+		}
+	}
+}
+
+// echoMonitor logs the echo delay whenever the estimate changes
+func echoMonitor(aec *dsp.AEC) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastDelay := -1
+	for range ticker.C {
+		if delay := aec.Delay(); delay != lastDelay && delay >= 0 {
+			log.Printf("Echo delay: %.1f ms", float64(delay)*1000/input.SampleRate)
+			lastDelay = delay
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package gui
 
 import (
+	"errors"
 	"fmt"
 	"image/color"
+	"io/fs"
 	"log"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -12,7 +16,9 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/errakhaoui/noise-canceling/dsp"
 	"github.com/errakhaoui/noise-canceling/input"
+	"github.com/errakhaoui/noise-canceling/latency"
 	"github.com/errakhaoui/noise-canceling/noise_canceller"
 	"github.com/errakhaoui/noise-canceling/output"
 	"github.com/gordonklaus/portaudio"
@@ -26,16 +32,143 @@ type AudioProcessor struct {
 	inputDeviceIndex   int
 	outputDeviceIndex  int
 	monitorDeviceIndex int
+	referenceIndex     int
 	noiseCancelEnabled bool
+	strength           float32
+	gateThreshold      float32
+	modelPath          string
+	softClip           bool
+	stereo             bool
+	channelMode        noise_canceller.ChannelMode
+	agcEnabled         bool
+	agcTargetDB        float32
+	highPassEnabled    bool
+	highPassCutoff     float64
+	eqPreset           string
+	compressorEnabled  bool
+	compThresholdDB    float32
+	limiterEnabled     bool
+	humEnabled         bool
+	clickEnabled       bool
+	clickSensitivity   float32
+	comfortEnabled     bool
+	comfortLevelDB     float32
+	lastInputDevice    string          // input device of the previous session
+	streams            latency.Streams // stream delays of the running session
+	latency            time.Duration   // total latency last shown
 }
 
 var processor = &AudioProcessor{
 	running:            false,
 	stopChan:           make(chan bool),
 	noiseCancelEnabled: true,
+	strength:           1,
 	inputDeviceIndex:   -1,
 	outputDeviceIndex:  -1,
 	monitorDeviceIndex: -1,
+	referenceIndex:     -1,
+	channelMode:        noise_canceller.Linked,
+	agcTargetDB:        dsp.DefaultAGCConfig().TargetDB,
+	highPassEnabled:    true,
+	highPassCutoff:     dsp.DefaultHighPassCutoff,
+	clickSensitivity:   dsp.DefaultClickConfig().Sensitivity,
+	comfortLevelDB:     dsp.DefaultComfortNoiseConfig().LevelDB,
+	eqPreset:           "flat",
+	compThresholdDB:    dsp.DefaultCompressorConfig().ThresholdDB,
+	limiterEnabled:     true,
+}
+
+// aec removes speaker echo from the microphone; enabled when an echo
+// reference device is selected
+var aec = dsp.NewAEC(input.SampleRate, 1, dsp.DefaultAECConfig())
+
+// highPass removes DC offset and rumble before denoising
+var highPass = dsp.NewHighPass(input.SampleRate, 1, dsp.DefaultHighPassCutoff)
+
+// humRemover notches out mains hum once it is detected
+var humRemover = newHumRemover()
+
+// subtractor removes the learned noise profile of the input device before
+// RNNoise, after the noise estimator so comfort noise matches the real room
+var subtractor = dsp.NewNoiseSubtractor(input.SampleRate, 1, dsp.DefaultSubtractionConfig())
+
+// learnDuration is how long "Learn Noise" listens to the room
+const learnDuration = 3 * time.Second
+
+// denoiseStage runs RNNoise and provides voice activity to later stages
+var denoiseStage = noise_canceller.DefaultStage()
+
+// clickSuppressor ducks keyboard clicks RNNoise lets through, using its
+// voice activity to leave plosives alone
+var clickSuppressor = newClickSuppressor()
+
+// noiseEstimator measures the background before RNNoise removes it
+var noiseEstimator = dsp.NewNoiseEstimator(input.SampleRate, 1)
+
+// comfortNoise fills pauses with noise like the measured background (off
+// until enabled in the UI)
+var comfortNoise = newComfortNoise()
+
+// eq shapes the denoised voice; flat until a preset is picked in the UI
+var eq = newEQ()
+
+// agc evens out speech levels after denoising (off until enabled in the UI)
+var agc = newAGC()
+
+// compressor evens out loud syllables (off until enabled in the UI)
+var compressor = newCompressor()
+
+// limiter stops the output from clipping; it must stay the last stage
+var limiter = dsp.NewLimiter(input.SampleRate, 1, dsp.DefaultLimiterConfig())
+
+// Pipeline holds the processing stages run on every frame, in order. Stages
+// can be inserted before or after RNNoise, even while audio is running.
+var Pipeline = dsp.NewChain(aec, highPass, humRemover, noiseEstimator, subtractor, denoiseStage, clickSuppressor, comfortNoise, eq, agc, compressor, limiter)
+
+// newComfortNoise creates the pipeline's comfort noise generator, initially
+// disabled
+func newComfortNoise() *dsp.ComfortNoise {
+	c := dsp.NewComfortNoise(input.SampleRate, 1, dsp.DefaultComfortNoiseConfig(), noiseEstimator, denoiseStage)
+	c.SetEnabled(false)
+	return c
+}
+
+// newHumRemover creates the pipeline's hum remover, initially disabled
+func newHumRemover() *dsp.HumRemover {
+	h := dsp.NewHumRemover(input.SampleRate, 1, dsp.DefaultHumConfig())
+	h.SetEnabled(false)
+	return h
+}
+
+// newClickSuppressor creates the pipeline's click suppressor, initially
+// disabled
+func newClickSuppressor() *dsp.ClickSuppressor {
+	c := dsp.NewClickSuppressor(input.SampleRate, 1, dsp.DefaultClickConfig(), denoiseStage)
+	c.SetEnabled(false)
+	return c
+}
+
+// newCompressor creates the pipeline's compressor, initially disabled
+func newCompressor() *dsp.Compressor {
+	c := dsp.NewCompressor(input.SampleRate, 1, dsp.DefaultCompressorConfig())
+	c.SetEnabled(false)
+	return c
+}
+
+// newEQ creates the pipeline's equalizer with no bands
+func newEQ() *dsp.EQ {
+	e, err := dsp.NewEQ(input.SampleRate, 1, nil)
+	if err != nil {
+		panic(err) // No bands can't be invalid
+	}
+	return e
+}
+
+// newAGC creates the pipeline's AGC, initially disabled
+func newAGC() *dsp.AGC {
+	a := dsp.NewAGC(input.SampleRate, 1, dsp.DefaultAGCConfig(), denoiseStage)
+	a.SetEnabled(false)
+	return a
 }
 
 // getInputDevices returns all available input devices
@@ -95,7 +228,72 @@ func startAudioProcessing(inputDevices, outputDevices []*portaudio.DeviceInfo) e
 	processor.mu.Unlock()
 
 	// Initialize input
-	input.StartMicAcquisition()
+	var inputDevice *portaudio.DeviceInfo
+	if processor.inputDeviceIndex >= 0 && processor.inputDeviceIndex < len(inputDevices) {
+		inputDevice = inputDevices[processor.inputDeviceIndex]
+	} else {
+		defaultDevice, err := portaudio.DefaultInputDevice()
+		if err != nil {
+			processor.mu.Lock()
+			processor.running = false
+			processor.mu.Unlock()
+			return err
+		}
+		inputDevice = defaultDevice
+	}
+
+	channels := 1
+	if processor.stereo {
+		channels = 2
+	}
+	if err := noise_canceller.SetChannels(channels, processor.channelMode); err != nil {
+		processor.mu.Lock()
+		processor.running = false
+		processor.mu.Unlock()
+		return err
+	}
+	agc.SetChannels(channels)
+	highPass.SetChannels(channels)
+	eq.SetChannels(channels)
+	compressor.SetChannels(channels)
+	limiter.SetChannels(channels)
+	aec.SetChannels(channels)
+	humRemover.SetChannels(channels)
+	subtractor.SetChannels(channels)
+	clickSuppressor.SetChannels(channels)
+	noiseEstimator.SetChannels(channels)
+	comfortNoise.SetChannels(channels)
+
+	// State adapted to another microphone (or room) would leak into this
+	// session, so start from scratch when the input device changes
+	if processor.lastInputDevice != "" && processor.lastInputDevice != inputDevice.Name {
+		log.Printf("Input device changed from %s to %s, resetting processing state", processor.lastInputDevice, inputDevice.Name)
+		Pipeline.Reset()
+	}
+	processor.mu.Lock()
+	processor.lastInputDevice = inputDevice.Name
+	processor.mu.Unlock()
+	loadNoiseProfile(inputDevice.Name)
+
+	if err := input.StartMicAcquisitionWithChannels(inputDevice, channels); err != nil {
+		processor.mu.Lock()
+		processor.running = false
+		processor.mu.Unlock()
+		return err
+	}
+
+	// Capture the echo reference alongside the microphone
+	aec.SetEnabled(false)
+	if processor.referenceIndex >= 0 && processor.referenceIndex < len(inputDevices) {
+		if err := input.StartReferenceCapture(inputDevices[processor.referenceIndex]); err != nil {
+			input.Close()
+			processor.mu.Lock()
+			processor.running = false
+			processor.mu.Unlock()
+			return err
+		}
+		aec.SetEnabled(true)
+	}
 
 	// Initialize output devices
 	var devicesToUse []*portaudio.DeviceInfo
@@ -106,8 +304,9 @@ func startAudioProcessing(inputDevices, outputDevices []*portaudio.DeviceInfo) e
 		devicesToUse = append(devicesToUse, outputDevices[processor.monitorDeviceIndex])
 	}
 
-	if err := output.StartOutputStreamToDevices(devicesToUse); err != nil {
+	if err := output.StartOutputStreamToDevicesWithChannels(devicesToUse, channels); err != nil {
 		input.Close()
+		input.CloseReference()
 		processor.mu.Lock()
 		processor.running = false
 		processor.mu.Unlock()
@@ -120,6 +319,21 @@ func startAudioProcessing(inputDevices, outputDevices []*portaudio.DeviceInfo) e
 	} else {
 		noise_canceller.Disable()
 	}
+	noise_canceller.SetStrength(processor.strength)
+	noise_canceller.SetGateThreshold(processor.gateThreshold)
+	noise_canceller.SetSoftClip(processor.softClip)
+	streams := latency.Streams{
+		Input:      input.Latency(),
+		Frame:      input.FrameDuration,
+		Output:     output.Latency(),
+		SampleRate: input.SampleRate,
+	}
+	report := latency.Measure(streams, Pipeline)
+	processor.mu.Lock()
+	processor.streams = streams
+	processor.latency = report.Total()
+	processor.mu.Unlock()
+	log.Printf("Latency: %v", report)
 
 	// Start processing loop in a goroutine
 	go func() {
@@ -130,8 +344,16 @@ func startAudioProcessing(inputDevices, outputDevices []*portaudio.DeviceInfo) e
 			default:
 				// Read audio from the input stream
 				input.ReadStream()
-				noise_canceller.Execute(input.InputBuffer)
-				output.ReadStream(input.InputBuffer)
+				if input.ReferenceActive() {
+					input.ReadReference()
+					if err := aec.SetReference(input.ReferenceBufferFloat32); err != nil {
+						log.Printf("Error setting echo reference: %v", err)
+					}
+				}
+				if err := Pipeline.Process(input.InputBufferFloat32); err != nil {
+					log.Printf("Error processing audio: %v", err)
+				}
+				output.ReadStreamFloat32(input.InputBufferFloat32)
 			}
 		}
 	}()
@@ -150,11 +372,14 @@ func stopAudioProcessing() {
 
 	processor.stopChan <- true
 	processor.running = false
+	subtractor.CancelLearn()
 
 	// Close audio streams (but keep RNNoise state alive for restart)
 	input.Close()
+	input.CloseReference()
 	output.Close()
-	// Note: noise_canceller is NOT closed here - it persists across start/stop cycles
+	// Note: noise_canceller is NOT closed here - it persists across start/stop
+	// cycles and is reset by startAudioProcessing if the input device changes
 }
 
 // toggleNoiseCancellation toggles noise cancellation on/off
@@ -170,11 +395,445 @@ func toggleNoiseCancellation(enabled bool) {
 	}
 }
 
+// loadModel switches the denoiser to the RNNoise model at path (empty path
+// restores the built-in model); only allowed while audio is stopped
+func loadModel(path string) error {
+	processor.mu.Lock()
+	defer processor.mu.Unlock()
+
+	if processor.running {
+		return fmt.Errorf("stop audio processing before changing the model")
+	}
+	if err := noise_canceller.LoadModel(path); err != nil {
+		return err
+	}
+	processor.modelPath = path
+	return nil
+}
+
+// modelText formats the active model for display
+func modelText(path string) string {
+	if path == "" {
+		return "Model: Built-in"
+	}
+	return "Model: " + filepath.Base(path)
+}
+
+// setStrength updates the suppression strength (0-1)
+func setStrength(strength float32) {
+	processor.mu.Lock()
+	processor.strength = strength
+	processor.mu.Unlock()
+
+	noise_canceller.SetStrength(strength)
+}
+
+// setSoftClip enables or disables soft clipping of output peaks
+func setSoftClip(enabled bool) {
+	processor.mu.Lock()
+	processor.softClip = enabled
+	processor.mu.Unlock()
+
+	noise_canceller.SetSoftClip(enabled)
+}
+
+// setHighPass enables or disables the high-pass filter
+func setHighPass(enabled bool) {
+	processor.mu.Lock()
+	processor.highPassEnabled = enabled
+	processor.mu.Unlock()
+
+	highPass.SetEnabled(enabled)
+}
+
+// setHighPassCutoff moves the high-pass corner frequency
+func setHighPassCutoff(cutoff float64) {
+	processor.mu.Lock()
+	processor.highPassCutoff = cutoff
+	processor.mu.Unlock()
+
+	highPass.SetCutoff(cutoff)
+}
+
+// setHumRemoval enables or disables hum detection and removal
+func setHumRemoval(enabled bool) {
+	processor.mu.Lock()
+	processor.humEnabled = enabled
+	processor.mu.Unlock()
+
+	humRemover.SetEnabled(enabled)
+}
+
+// humText describes the detected hum for display
+func humText(frequency float64) string {
+	if frequency <= 0 {
+		return "Hum: none detected"
+	}
+	return fmt.Sprintf("Hum: removing %.2f Hz and harmonics", frequency)
+}
+
+// monitorHum shows the detected hum frequency while hum removal runs
+func monitorHum(label *widget.Label) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		text := ""
+		if isRunning() && humRemover.Enabled() {
+			text = humText(humRemover.Frequency())
+		}
+
+		fyne.Do(func() {
+			label.SetText(text)
+		})
+	}
+}
+
+// setClickSuppression enables or disables keyboard click suppression
+func setClickSuppression(enabled bool) {
+	processor.mu.Lock()
+	processor.clickEnabled = enabled
+	processor.mu.Unlock()
+
+	clickSuppressor.SetEnabled(enabled)
+}
+
+// setClickSensitivity sets how small a transient is treated as a click
+func setClickSensitivity(sensitivity float32) {
+	processor.mu.Lock()
+	processor.clickSensitivity = sensitivity
+	processor.mu.Unlock()
+
+	clickSuppressor.SetSensitivity(sensitivity)
+}
+
+// clickSensitivityText formats the click sensitivity for display
+func clickSensitivityText(sensitivity float32) string {
+	return fmt.Sprintf("Click Sensitivity: %.0f%%", sensitivity*100)
+}
+
+// setComfortNoise enables or disables comfort noise in pauses
+func setComfortNoise(enabled bool) {
+	processor.mu.Lock()
+	processor.comfortEnabled = enabled
+	processor.mu.Unlock()
+
+	comfortNoise.SetEnabled(enabled)
+}
+
+// setComfortLevel sets the comfort noise level relative to the background
+func setComfortLevel(db float32) {
+	processor.mu.Lock()
+	processor.comfortLevelDB = db
+	processor.mu.Unlock()
+
+	comfortNoise.SetLevelDB(db)
+}
+
+// comfortLevelText formats the comfort noise level for display
+func comfortLevelText(db float32) string {
+	return fmt.Sprintf("Comfort Noise Level: %+.0f dB vs. room", db)
+}
+
+// runningStatusText shows the end-to-end latency in the status line
+func runningStatusText(report latency.Report) string {
+	return fmt.Sprintf("Status: Running - latency %s", latency.Milliseconds(report.Total()))
+}
+
+// highPassText formats the high-pass cutoff for display
+func highPassText(cutoff float64) string {
+	return fmt.Sprintf("High-Pass Cutoff: %.0f Hz", cutoff)
+}
+
+// setEQPreset switches the equalizer to a built-in preset
+func setEQPreset(name string) error {
+	bands, err := dsp.EQPreset(name)
+	if err != nil {
+		return err
+	}
+	if err := eq.SetBands(bands); err != nil {
+		return err
+	}
+
+	processor.mu.Lock()
+	processor.eqPreset = name
+	processor.mu.Unlock()
+	return nil
+}
+
+// setAGC enables or disables automatic gain control
+func setAGC(enabled bool) {
+	processor.mu.Lock()
+	processor.agcEnabled = enabled
+	processor.mu.Unlock()
+
+	agc.SetEnabled(enabled)
+}
+
+// setAGCTarget updates the AGC target speech level in dBFS
+func setAGCTarget(db float32) {
+	processor.mu.Lock()
+	processor.agcTargetDB = db
+	processor.mu.Unlock()
+
+	agc.SetTargetDB(db)
+}
+
+// setCompressor enables or disables the compressor
+func setCompressor(enabled bool) {
+	processor.mu.Lock()
+	processor.compressorEnabled = enabled
+	processor.mu.Unlock()
+
+	compressor.SetEnabled(enabled)
+}
+
+// setCompressorThreshold updates the compressor threshold in dBFS
+func setCompressorThreshold(db float32) {
+	processor.mu.Lock()
+	processor.compThresholdDB = db
+	processor.mu.Unlock()
+
+	compressor.SetThresholdDB(db)
+}
+
+// compressorThresholdText formats the compressor threshold for display
+func compressorThresholdText(db float32) string {
+	return fmt.Sprintf("Compressor Threshold: %.0f dBFS", db)
+}
+
+// setLimiter enables or disables the output limiter
+func setLimiter(enabled bool) {
+	processor.mu.Lock()
+	processor.limiterEnabled = enabled
+	processor.mu.Unlock()
+
+	limiter.SetEnabled(enabled)
+}
+
+// gainReductionText formats the compressor and limiter gain reduction
+func gainReductionText(compressorDB, limiterDB float32) string {
+	return fmt.Sprintf("Gain Reduction: compressor %.1f dB, limiter %.1f dB", compressorDB, limiterDB)
+}
+
+// maxMeterDB is the gain reduction shown as a full meter
+const maxMeterDB = 20
+
+// monitorGainReduction updates the gain reduction meter while audio runs
+func monitorGainReduction(label *widget.Label, meter *widget.ProgressBar) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var compressorDB, limiterDB float32
+		if isRunning() {
+			compressorDB = compressor.GainReductionDB()
+			limiterDB = limiter.GainReductionDB()
+		}
+		total := float64(compressorDB + limiterDB)
+
+		fyne.Do(func() {
+			label.SetText(gainReductionText(compressorDB, limiterDB))
+			meter.SetValue(min(total, maxMeterDB))
+		})
+	}
+}
+
+// echoText describes the echo canceller's state for display
+func echoText(delay int, doubleTalk bool) string {
+	if delay < 0 {
+		return "Echo Cancellation: measuring delay..."
+	}
+	text := fmt.Sprintf("Echo Cancellation: delay %.0f ms", float64(delay)*1000/input.SampleRate)
+	if doubleTalk {
+		text += " (double talk)"
+	}
+	return text
+}
+
+// monitorEcho shows the echo delay while echo cancellation runs
+func monitorEcho(label *widget.Label) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		text := ""
+		if isRunning() && aec.Enabled() {
+			text = echoText(aec.Delay(), aec.DoubleTalk())
+		}
+
+		fyne.Do(func() {
+			label.SetText(text)
+		})
+	}
+}
+
+// loadNoiseProfile applies the saved noise profile of an input device, or
+// none if it was never learned
+func loadNoiseProfile(device string) {
+	var profile *dsp.NoiseProfile
+	path, err := dsp.NoiseProfilePath(device)
+	if err == nil {
+		profile, err = dsp.LoadNoiseProfile(path)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Error loading noise profile for %s: %v", device, err)
+	}
+	if profile != nil {
+		log.Printf("Loaded noise profile for %s", device)
+	}
+
+	if err := subtractor.SetProfile(profile); err != nil {
+		log.Printf("Error applying noise profile for %s: %v", device, err)
+		_ = subtractor.SetProfile(nil) // Removing a profile can't fail
+	}
+}
+
+// learnNoise captures the background noise of the running input device and
+// saves it as the device's profile
+func learnNoise() error {
+	processor.mu.Lock()
+	device := processor.lastInputDevice
+	processor.mu.Unlock()
+
+	// Stopping cancels the capture, so its channel never receives; give up
+	// as soon as processing stops rather than waiting for the timeout
+	done := subtractor.Learn(learnDuration)
+	timeout := time.After(learnDuration + 2*time.Second)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case profile := <-done:
+			path, err := dsp.NoiseProfilePath(device)
+			if err != nil {
+				return err
+			}
+			if err := profile.Save(path); err != nil {
+				return err
+			}
+			log.Printf("Saved noise profile for %s to %s", device, path)
+			return nil
+		case <-ticker.C:
+			if !isRunning() {
+				return fmt.Errorf("audio processing stopped while learning")
+			}
+		case <-timeout:
+			// Don't let a stale capture replace a profile on the next start
+			subtractor.CancelLearn()
+			return fmt.Errorf("no audio was processed while learning")
+		}
+	}
+}
+
+// noiseProfileText describes the noise profile for display
+func noiseProfileText() string {
+	switch {
+	case subtractor.Learning():
+		return fmt.Sprintf("Noise Profile: learning - stay silent for %v...", learnDuration)
+	case subtractor.Profile() != nil:
+		return "Noise Profile: learned"
+	default:
+		return "Noise Profile: none"
+	}
+}
+
+// setNoiseSubtraction turns subtraction of the noise profile on or off
+func setNoiseSubtraction(enabled bool) {
+	subtractor.SetEnabled(enabled)
+}
+
+// isRunning reports whether audio processing is active
+func isRunning() bool {
+	processor.mu.Lock()
+	defer processor.mu.Unlock()
+	return processor.running
+}
+
+// measureLatency reports the latency of the running session with the
+// pipeline as it is now
+func measureLatency() latency.Report {
+	processor.mu.Lock()
+	streams := processor.streams
+	processor.mu.Unlock()
+	return latency.Measure(streams, Pipeline)
+}
+
+// monitorLatency logs and shows the latency whenever it changes while
+// running, such as when a noise profile is loaded or learned
+func monitorLatency(status *widget.Label) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report := measureLatency()
+		processor.mu.Lock()
+		changed := processor.running && report.Total() != processor.latency
+		if changed {
+			processor.latency = report.Total()
+		}
+		processor.mu.Unlock()
+		if !changed {
+			continue
+		}
+
+		log.Printf("Latency: %v", report)
+		fyne.Do(func() {
+			if isRunning() {
+				status.SetText(runningStatusText(report))
+			}
+		})
+	}
+}
+
+// monitorClipping shows a warning whenever the output clipped during the
+// last interval, so users know to lower their input gain
+func monitorClipping(warning *widget.Label) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastCount := noise_canceller.ClipCount()
+	for range ticker.C {
+		count := noise_canceller.ClipCount()
+		text := ""
+		if isRunning() && count > lastCount {
+			text = fmt.Sprintf("⚠ Clipping (%d samples) - lower your input gain", count-lastCount)
+		}
+		lastCount = count
+
+		fyne.Do(func() {
+			warning.SetText(text)
+		})
+	}
+}
+
+// setGateThreshold updates the voice probability below which audio is gated
+func setGateThreshold(threshold float32) {
+	processor.mu.Lock()
+	processor.gateThreshold = threshold
+	processor.mu.Unlock()
+
+	noise_canceller.SetGateThreshold(threshold)
+}
+
+// gateThresholdText formats the gate threshold for display
+func gateThresholdText(threshold float64) string {
+	if threshold <= 0 {
+		return "Noise Gate Threshold: Off"
+	}
+	return fmt.Sprintf("Noise Gate Threshold: %.2f", threshold)
+}
+
+// agcTargetText formats the AGC target level for display
+func agcTargetText(db float32) string {
+	return fmt.Sprintf("AGC Target Level: %.0f dBFS", db)
+}
+
 // CreateGUI creates and displays the main GUI window
 func CreateGUI() {
 	myApp := app.New()
 	myWindow := myApp.NewWindow("ClearVox")
-	myWindow.Resize(fyne.NewSize(450, 400))
+	myWindow.Resize(fyne.NewSize(450, 720))
 
 	// Get available devices
 	inputDevices, err := getInputDevices()
@@ -270,16 +929,205 @@ func CreateGUI() {
 	})
 	monitorSelect.SetSelected("None")
 
+	referenceLabel := widget.NewLabel("Echo Reference (speaker loopback, optional):")
+	referenceSelect := widget.NewSelect(append([]string{"None"}, inputDeviceNames...), func(value string) {
+		processor.referenceIndex = -1
+		for i, name := range inputDeviceNames {
+			if name == value {
+				processor.referenceIndex = i
+				break
+			}
+		}
+	})
+	referenceSelect.SetSelected("None")
+	echoLabel := widget.NewLabel("")
+	go monitorEcho(echoLabel)
+
 	noiseCancelCheck := widget.NewCheck("Enable Noise Cancellation", func(checked bool) {
 		toggleNoiseCancellation(checked)
 	})
 	noiseCancelCheck.SetChecked(true)
 
+	strengthLabel := widget.NewLabel("Suppression Strength: 100%")
+	strengthSlider := widget.NewSlider(0, 100)
+	strengthSlider.Step = 5
+	strengthSlider.SetValue(100)
+	strengthSlider.OnChanged = func(value float64) {
+		strengthLabel.SetText(fmt.Sprintf("Suppression Strength: %.0f%%", value))
+		setStrength(float32(value / 100))
+	}
+
+	gateLabel := widget.NewLabel(gateThresholdText(0))
+	gateSlider := widget.NewSlider(0, 1)
+	gateSlider.Step = 0.05
+	gateSlider.OnChanged = func(value float64) {
+		gateLabel.SetText(gateThresholdText(value))
+		setGateThreshold(float32(value))
+	}
+
+	clickCheck := widget.NewCheck("Keyboard Click Suppression", setClickSuppression)
+	clickCheck.SetChecked(processor.clickEnabled)
+	clickSensitivityLabel := widget.NewLabel(clickSensitivityText(processor.clickSensitivity))
+	clickSensitivitySlider := widget.NewSlider(0, 100)
+	clickSensitivitySlider.Step = 5
+	clickSensitivitySlider.SetValue(float64(processor.clickSensitivity * 100))
+	clickSensitivitySlider.OnChanged = func(value float64) {
+		clickSensitivityLabel.SetText(clickSensitivityText(float32(value / 100)))
+		setClickSensitivity(float32(value / 100))
+	}
+
+	comfortCheck := widget.NewCheck("Comfort Noise (fills pauses)", setComfortNoise)
+	comfortCheck.SetChecked(processor.comfortEnabled)
+	comfortLevelLabel := widget.NewLabel(comfortLevelText(processor.comfortLevelDB))
+	comfortLevelSlider := widget.NewSlider(-30, 0)
+	comfortLevelSlider.Step = 1
+	comfortLevelSlider.SetValue(float64(processor.comfortLevelDB))
+	comfortLevelSlider.OnChanged = func(value float64) {
+		comfortLevelLabel.SetText(comfortLevelText(float32(value)))
+		setComfortLevel(float32(value))
+	}
+
+	softClipCheck := widget.NewCheck("Soft Clipping", func(checked bool) {
+		setSoftClip(checked)
+	})
+
+	stereoCheck := widget.NewCheck("Stereo", func(checked bool) {
+		processor.stereo = checked
+	})
+	channelModeSelect := widget.NewSelect([]string{
+		noise_canceller.Linked.String(),
+		noise_canceller.Independent.String(),
+	}, func(value string) {
+		if mode, err := noise_canceller.ParseChannelMode(value); err == nil {
+			processor.channelMode = mode
+		}
+	})
+	channelModeSelect.SetSelected(processor.channelMode.String())
+	channelContainer := container.NewHBox(stereoCheck, widget.NewLabel("Channel Mode:"), channelModeSelect)
+
+	highPassCheck := widget.NewCheck("High-Pass Filter (removes rumble)", func(checked bool) {
+		setHighPass(checked)
+	})
+	highPassCheck.SetChecked(processor.highPassEnabled)
+	humCheck := widget.NewCheck("Hum Removal (50/60 Hz, auto-detected)", setHumRemoval)
+	humCheck.SetChecked(processor.humEnabled)
+	humLabel := widget.NewLabel("")
+	go monitorHum(humLabel)
+
+	highPassLabel := widget.NewLabel(highPassText(processor.highPassCutoff))
+	highPassSlider := widget.NewSlider(20, 200)
+	highPassSlider.Step = 10
+	highPassSlider.SetValue(processor.highPassCutoff)
+	highPassSlider.OnChanged = func(value float64) {
+		highPassLabel.SetText(highPassText(value))
+		setHighPassCutoff(value)
+	}
+
+	eqSelect := widget.NewSelect(dsp.EQPresets(), func(value string) {
+		if err := setEQPreset(value); err != nil {
+			log.Printf("Error setting EQ preset: %v", err)
+		}
+	})
+	eqSelect.SetSelected(processor.eqPreset)
+	eqContainer := container.NewHBox(widget.NewLabel("Voice EQ:"), eqSelect)
+
+	agcTargetLabel := widget.NewLabel(agcTargetText(processor.agcTargetDB))
+	agcTargetSlider := widget.NewSlider(-30, -6)
+	agcTargetSlider.Step = 1
+	agcTargetSlider.SetValue(float64(processor.agcTargetDB))
+	agcTargetSlider.OnChanged = func(value float64) {
+		agcTargetLabel.SetText(agcTargetText(float32(value)))
+		setAGCTarget(float32(value))
+	}
+	agcCheck := widget.NewCheck("Automatic Gain Control", func(checked bool) {
+		setAGC(checked)
+	})
+
+	compThresholdLabel := widget.NewLabel(compressorThresholdText(processor.compThresholdDB))
+	compThresholdSlider := widget.NewSlider(-40, -6)
+	compThresholdSlider.Step = 1
+	compThresholdSlider.SetValue(float64(processor.compThresholdDB))
+	compThresholdSlider.OnChanged = func(value float64) {
+		compThresholdLabel.SetText(compressorThresholdText(float32(value)))
+		setCompressorThreshold(float32(value))
+	}
+	compressorCheck := widget.NewCheck("Compressor", func(checked bool) {
+		setCompressor(checked)
+	})
+	limiterCheck := widget.NewCheck("Limiter (prevents clipping)", func(checked bool) {
+		setLimiter(checked)
+	})
+	limiterCheck.SetChecked(processor.limiterEnabled)
+	dynamicsContainer := container.NewHBox(compressorCheck, limiterCheck)
+
+	gainReductionLabel := widget.NewLabel(gainReductionText(0, 0))
+	gainReductionMeter := widget.NewProgressBar()
+	gainReductionMeter.Max = maxMeterDB
+	gainReductionMeter.TextFormatter = func() string {
+		return fmt.Sprintf("%.1f dB", gainReductionMeter.Value)
+	}
+	go monitorGainReduction(gainReductionLabel, gainReductionMeter)
+
+	noiseProfileLabel := widget.NewLabel(noiseProfileText())
+	subtractionCheck := widget.NewCheck("Subtract Noise Profile", setNoiseSubtraction)
+	subtractionCheck.SetChecked(true)
+	learnButton := widget.NewButton("Learn Noise", nil)
+	learnButton.Disable()
+	learnButton.OnTapped = func() {
+		learnButton.Disable()
+		go func() {
+			err := learnNoise()
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("failed to learn noise: %w", err), myWindow)
+				}
+				noiseProfileLabel.SetText(noiseProfileText())
+				if isRunning() {
+					learnButton.Enable()
+				}
+			})
+		}()
+		noiseProfileLabel.SetText(noiseProfileText())
+	}
+	noiseProfileContainer := container.NewHBox(learnButton, subtractionCheck)
+
+	modelLabel := widget.NewLabel(modelText(""))
+	loadModelButton := widget.NewButton("Load Model...", func() {
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+			if reader == nil {
+				return // Cancelled
+			}
+			path := reader.URI().Path()
+			_ = reader.Close() // Only the path is needed
+
+			if err := loadModel(path); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to load model: %w", err), myWindow)
+				return
+			}
+			modelLabel.SetText(modelText(path))
+		}, myWindow)
+	})
+	builtinModelButton := widget.NewButton("Use Built-in", func() {
+		if err := loadModel(""); err != nil {
+			dialog.ShowError(err, myWindow)
+			return
+		}
+		modelLabel.SetText(modelText(""))
+	})
+	modelContainer := container.NewHBox(modelLabel, loadModelButton, builtinModelButton)
+
 	// Status indicator
 	statusCircle := canvas.NewCircle(color.NRGBA{R: 255, G: 0, B: 0, A: 255}) // Red = stopped
 	statusCircle.Resize(fyne.NewSize(15, 15))
 	statusLabel := widget.NewLabel("Status: Stopped")
 	statusContainer := container.NewHBox(statusCircle, statusLabel)
+	clipWarning := widget.NewLabel("")
+	go monitorClipping(clipWarning)
+	go monitorLatency(statusLabel)
 
 	// Start/Stop buttons
 	startButton := widget.NewButton("Start", nil)
@@ -296,12 +1144,19 @@ func CreateGUI() {
 
 		statusCircle.FillColor = color.NRGBA{R: 0, G: 255, B: 0, A: 255} // Green = running
 		statusCircle.Refresh()
-		statusLabel.SetText("Status: Running")
+		statusLabel.SetText(runningStatusText(measureLatency()))
+		noiseProfileLabel.SetText(noiseProfileText())
 		startButton.Disable()
 		stopButton.Enable()
+		learnButton.Enable()
 		inputSelect.Disable()
 		outputSelect.Disable()
 		monitorSelect.Disable()
+		referenceSelect.Disable()
+		stereoCheck.Disable()
+		channelModeSelect.Disable()
+		loadModelButton.Disable()
+		builtinModelButton.Disable()
 	}
 
 	stopButton.OnTapped = func() {
@@ -312,30 +1167,75 @@ func CreateGUI() {
 		statusLabel.SetText("Status: Stopped")
 		startButton.Enable()
 		stopButton.Disable()
+		learnButton.Disable()
 		inputSelect.Enable()
 		outputSelect.Enable()
 		monitorSelect.Enable()
+		referenceSelect.Enable()
+		stereoCheck.Enable()
+		channelModeSelect.Enable()
+		loadModelButton.Enable()
+		builtinModelButton.Enable()
 	}
 
 	buttonContainer := container.NewHBox(startButton, stopButton)
 
-	// Layout
-	content := container.NewVBox(
+	// Layout: the controls scroll so Start/Stop and the status stay on
+	// screen however many stages there are
+	header := container.NewVBox(
 		widget.NewLabelWithStyle("ClearVox", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
 		widget.NewSeparator(),
+	)
+	controls := container.NewVBox(
 		inputLabel,
 		inputSelect,
 		outputLabel,
 		outputSelect,
 		monitorLabel,
 		monitorSelect,
+		referenceLabel,
+		referenceSelect,
+		echoLabel,
+		channelContainer,
 		widget.NewSeparator(),
+		highPassCheck,
+		highPassLabel,
+		highPassSlider,
+		humCheck,
+		humLabel,
+		noiseProfileContainer,
+		noiseProfileLabel,
 		noiseCancelCheck,
+		strengthLabel,
+		strengthSlider,
+		gateLabel,
+		gateSlider,
+		clickCheck,
+		clickSensitivityLabel,
+		clickSensitivitySlider,
+		comfortCheck,
+		comfortLevelLabel,
+		comfortLevelSlider,
+		softClipCheck,
+		eqContainer,
+		agcCheck,
+		agcTargetLabel,
+		agcTargetSlider,
+		dynamicsContainer,
+		compThresholdLabel,
+		compThresholdSlider,
+		gainReductionLabel,
+		gainReductionMeter,
+		modelContainer,
+	)
+	footer := container.NewVBox(
 		widget.NewSeparator(),
 		buttonContainer,
 		widget.NewSeparator(),
 		statusContainer,
+		clipWarning,
 	)
+	content := container.NewBorder(header, footer, nil, nil, container.NewVScroll(controls))
 
 	myWindow.SetContent(content)
 
@@ -1,16 +1,38 @@
 package input
 
 import (
-	"github.com/gordonklaus/portaudio"
+	"fmt"
 	"log"
+	"math"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
 )
 
 const SampleRate = 48000
 const frameSize = 480
 
+// FrameDuration is how much audio ReadStream waits for before returning a
+// frame
+const FrameDuration = frameSize * time.Second / SampleRate
+
+// int16Scale converts between normalised float32 and int16 samples
+const int16Scale = 32768
+
 var InputBuffer []int16
+
+// InputBufferFloat32 holds the same frame as InputBuffer as normalised
+// (-1 to 1) float32 samples, captured without int16 quantisation. With more
+// than one channel the samples are interleaved.
+var InputBufferFloat32 []float32
 var inputStream *portaudio.Stream
 
+// Channels is the number of interleaved channels in the input buffers
+var Channels = 1
+
+// resampling is only set when the device can't capture at SampleRate
+var resampling *resampledCapture
+
 func init() {
 	if err := portaudio.Initialize(); err != nil {
 		log.Fatal(err)
@@ -18,25 +40,118 @@ func init() {
 }
 
 func StartMicAcquisition() {
-	var err error
-	InputBuffer = make([]int16, frameSize)
-
-	inputStream, err = portaudio.OpenDefaultStream(1, 0, SampleRate, len(InputBuffer), InputBuffer)
+	device, err := portaudio.DefaultInputDevice()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = inputStream.Start()
-	if err != nil {
+	if err := StartMicAcquisitionFromDevice(device); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func ReadStream() {
-	err := inputStream.Read()
+// StartMicAcquisitionFromDevice opens the given input device at SampleRate,
+// falling back to the device's native rate with resampling if it can't.
+// Audio is captured as float32; ReadStream fills both InputBufferFloat32
+// and the int16 InputBuffer.
+func StartMicAcquisitionFromDevice(device *portaudio.DeviceInfo) error {
+	return StartMicAcquisitionWithChannels(device, 1)
+}
+
+// StartMicAcquisitionWithChannels is like StartMicAcquisitionFromDevice but
+// captures the given number of channels, interleaved in the input buffers
+func StartMicAcquisitionWithChannels(device *portaudio.DeviceInfo, channels int) error {
+	if channels < 1 {
+		channels = 1
+	}
+	if device.MaxInputChannels < channels {
+		return fmt.Errorf("input device %s has %d channels, %d requested", device.Name, device.MaxInputChannels, channels)
+	}
+
+	resetResampling()
+	Channels = channels
+	InputBuffer = make([]int16, frameSize*channels)
+	InputBufferFloat32 = make([]float32, frameSize*channels)
+
+	stream, capture, err := openCapture(device, channels, InputBufferFloat32)
 	if err != nil {
+		return fmt.Errorf("error opening input stream on %s: %v", device.Name, err)
+	}
+	resampling = capture
+
+	if err := stream.Start(); err != nil {
+		_ = stream.Close() // Ignore error on cleanup
+		resetResampling()
+		return fmt.Errorf("error starting input stream on %s: %v", device.Name, err)
+	}
+
+	inputStream = stream
+	return nil
+}
+
+// openInputStream opens a blocking input stream that fills buffer with
+// interleaved samples
+func openInputStream(device *portaudio.DeviceInfo, rate, channels int, buffer []float32) (*portaudio.Stream, error) {
+	params := portaudio.HighLatencyParameters(device, nil)
+	params.Input.Channels = channels
+	params.SampleRate = float64(rate)
+	params.FramesPerBuffer = len(buffer) / channels
+	return portaudio.OpenStream(params, buffer)
+}
+
+func ReadStream() {
+	var err error
+	if resampling == nil {
+		err = inputStream.Read()
+	} else {
+		err = resampling.read(inputStream, InputBufferFloat32)
+	}
+	if readFailed(err) {
 		log.Fatal(err)
 	}
+	fillInt16Buffer()
+}
+
+// readFailed reports whether a stream read error means capture can't go on.
+// An input overflow still delivers a full frame; only earlier audio was lost.
+func readFailed(err error) bool {
+	return err != nil && err != portaudio.InputOverflowed
+}
+
+// Latency returns the capture delay of the open input stream as reported by
+// PortAudio, including the resampler when the device runs at another rate. It
+// returns 0 while no stream is open.
+func Latency() time.Duration {
+	if inputStream == nil {
+		return 0
+	}
+	info := inputStream.Info()
+	if info == nil {
+		return 0
+	}
+
+	latency := info.InputLatency
+	if resampling != nil {
+		latency += time.Duration(resampling.latency()) * time.Second / SampleRate
+	}
+	return latency
+}
+
+// fillInt16Buffer converts the captured float32 frame into InputBuffer
+func fillInt16Buffer() {
+	for i, sample := range InputBufferFloat32 {
+		InputBuffer[i] = toInt16(sample)
+	}
+}
+
+// toInt16 scales a normalised sample, rounds it and clamps it to the int16 range
+func toInt16(sample float32) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(float64(sample)*int16Scale))))
+}
+
+// resetResampling drops any resampling state from a previous stream
+func resetResampling() {
+	resampling = nil
 }
 
 func Close() {
@@ -49,6 +164,7 @@ func Close() {
 		}
 		inputStream = nil
 	}
+	resetResampling()
 }
 
 // Terminate closes PortAudio completely (call only on final exit)
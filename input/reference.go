@@ -0,0 +1,134 @@
+package input
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// ReferenceBufferFloat32 holds the far-end audio captured alongside the
+// current microphone frame, downmixed to mono. It is used as the echo
+// cancellation reference and stays silent when no reference is captured.
+var ReferenceBufferFloat32 = make([]float32, frameSize)
+
+var (
+	referenceStream     *portaudio.Stream
+	referenceCapture    []float32 // interleaved device channels
+	referenceChans      int
+	referenceResampling *resampledCapture // set when the device can't run at SampleRate
+)
+
+// ListInputDevices lists all available input devices, including loopback
+// devices that capture what the speakers play
+func ListInputDevices() ([]*portaudio.DeviceInfo, error) {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	var inputDevices []*portaudio.DeviceInfo
+	for _, device := range devices {
+		if device.MaxInputChannels > 0 {
+			inputDevices = append(inputDevices, device)
+		}
+	}
+	return inputDevices, nil
+}
+
+// FindInputDeviceByName finds an input device by name (case-insensitive,
+// partial match)
+func FindInputDeviceByName(name string) (*portaudio.DeviceInfo, error) {
+	devices, err := ListInputDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	searchName := strings.ToLower(name)
+	for _, device := range devices {
+		if strings.Contains(strings.ToLower(device.Name), searchName) {
+			return device, nil
+		}
+	}
+	return nil, fmt.Errorf("input device containing '%s' not found", name)
+}
+
+// StartReferenceCapture opens a second input stream on device, usually a
+// loopback of the default output (e.g., BlackHole, Stereo Mix or a monitor
+// source), to use as the echo cancellation reference. Devices that can't
+// run at SampleRate are captured at their native rate and resampled.
+func StartReferenceCapture(device *portaudio.DeviceInfo) error {
+	CloseReference()
+
+	channels := min(2, device.MaxInputChannels)
+	if channels < 1 {
+		return fmt.Errorf("device %s has no input channels", device.Name)
+	}
+
+	capture := make([]float32, frameSize*channels)
+	stream, resampling, err := openCapture(device, channels, capture)
+	if err != nil {
+		return fmt.Errorf("error opening echo reference stream on %s: %v", device.Name, err)
+	}
+	if err := stream.Start(); err != nil {
+		_ = stream.Close() // Ignore error on cleanup
+		return fmt.Errorf("error starting echo reference stream on %s: %v", device.Name, err)
+	}
+
+	referenceStream = stream
+	referenceCapture = capture
+	referenceChans = channels
+	referenceResampling = resampling
+	return nil
+}
+
+// ReadReference reads the far-end frame matching the last ReadStream into
+// ReferenceBufferFloat32. Read errors are logged and leave silence, since
+// losing the reference only degrades echo cancellation.
+func ReadReference() {
+	if referenceStream == nil {
+		return
+	}
+
+	var err error
+	if referenceResampling != nil {
+		err = referenceResampling.read(referenceStream, referenceCapture)
+	} else {
+		err = referenceStream.Read()
+	}
+	if readFailed(err) {
+		log.Printf("Error reading echo reference: %v", err)
+		clear(ReferenceBufferFloat32)
+		return
+	}
+
+	for i := range ReferenceBufferFloat32 {
+		var sum float32
+		for ch := 0; ch < referenceChans; ch++ {
+			sum += referenceCapture[i*referenceChans+ch]
+		}
+		ReferenceBufferFloat32[i] = sum / float32(referenceChans)
+	}
+}
+
+// ReferenceActive reports whether an echo reference is being captured
+func ReferenceActive() bool {
+	return referenceStream != nil
+}
+
+// CloseReference stops capturing the echo reference
+func CloseReference() {
+	if referenceStream != nil {
+		if err := referenceStream.Stop(); err != nil {
+			log.Printf("Error stopping echo reference stream: %v", err)
+		}
+		if err := referenceStream.Close(); err != nil {
+			log.Printf("Error closing echo reference stream: %v", err)
+		}
+		referenceStream = nil
+	}
+	referenceCapture = nil
+	referenceResampling = nil
+	clear(ReferenceBufferFloat32)
+}
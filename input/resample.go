@@ -0,0 +1,86 @@
+package input
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/errakhaoui/noise-canceling/dsp"
+	"github.com/gordonklaus/portaudio"
+)
+
+// resampledCapture reads a device that can't run at SampleRate in 10ms
+// blocks at its native rate and resamples them to SampleRate
+type resampledCapture struct {
+	deviceBuffer []float32 // filled by the stream, interleaved
+	resampler    *dsp.InterleavedResampler
+	resampleOut  []float32
+	pending      []float32 // resampled audio waiting to fill a frame
+}
+
+// newResampledCapture prepares resampling from rate for frames of frameLen
+// interleaved samples
+func newResampledCapture(rate, channels, frameLen int) *resampledCapture {
+	c := &resampledCapture{
+		deviceBuffer: make([]float32, rate/100*channels),
+		resampler:    dsp.NewInterleavedResampler(rate, SampleRate, channels, rate/100),
+	}
+	c.resampleOut = make([]float32, c.resampler.MaxOutput(len(c.deviceBuffer)))
+	c.pending = make([]float32, 0, frameLen+len(c.resampleOut))
+	return c
+}
+
+// openCapture opens a blocking input stream on device that fills frame with
+// interleaved samples at SampleRate. If the device can't open at SampleRate
+// it is opened at its native rate and the returned resampledCapture must be
+// used to read it; otherwise that is nil.
+func openCapture(device *portaudio.DeviceInfo, channels int, frame []float32) (*portaudio.Stream, *resampledCapture, error) {
+	stream, err := openInputStream(device, SampleRate, channels, frame)
+	if err == nil {
+		return stream, nil, nil
+	}
+
+	rate := int(device.DefaultSampleRate)
+	if rate <= 0 || rate == SampleRate {
+		return nil, nil, err
+	}
+
+	log.Printf("Input device %s can't open at %d Hz (%v), capturing at %d Hz with resampling",
+		device.Name, SampleRate, err, rate)
+
+	capture := newResampledCapture(rate, channels, len(frame))
+	stream, err = openInputStream(device, rate, channels, capture.deviceBuffer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("at %d Hz: %v", rate, err)
+	}
+	return stream, capture, nil
+}
+
+// blockReader is a blocking stream that fills its buffer on every Read
+type blockReader interface {
+	Read() error
+}
+
+// read keeps reading device blocks from stream until frame can be filled
+// with resampled audio. An input overflow doesn't stop the frame from being
+// filled; it is reported once the frame is complete.
+func (c *resampledCapture) read(stream blockReader, frame []float32) error {
+	var overflow error
+	for len(c.pending) < len(frame) {
+		if err := stream.Read(); err == portaudio.InputOverflowed {
+			overflow = err
+		} else if err != nil {
+			return err
+		}
+		n := c.resampler.Process(c.deviceBuffer, c.resampleOut)
+		c.pending = append(c.pending, c.resampleOut[:n]...)
+	}
+
+	copy(frame, c.pending)
+	c.pending = c.pending[:copy(c.pending, c.pending[len(frame):])]
+	return overflow
+}
+
+// latency returns the resampler's delay in samples at SampleRate
+func (c *resampledCapture) latency() int {
+	return c.resampler.Latency()
+}
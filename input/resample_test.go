@@ -0,0 +1,66 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// fakeStream fills a device buffer with a constant level and reports an
+// input overflow on the reads listed in overflows
+type fakeStream struct {
+	buffer    []float32
+	reads     int
+	overflows map[int]bool
+}
+
+func (s *fakeStream) Read() error {
+	s.reads++
+	for i := range s.buffer {
+		s.buffer[i] = 0.25
+	}
+	if s.overflows[s.reads] {
+		return portaudio.InputOverflowed
+	}
+	return nil
+}
+
+func TestResampledCaptureOverflow(t *testing.T) {
+	capture := newResampledCapture(44100, 1, frameSize)
+	stream := &fakeStream{buffer: capture.deviceBuffer, overflows: map[int]bool{2: true}}
+	frame := make([]float32, frameSize)
+
+	// Fill the resampler's history so the frames hold the input level
+	for i := 0; i < 5; i++ {
+		if err := capture.read(stream, frame); readFailed(err) {
+			t.Fatalf("read %d failed: %v", i, err)
+		}
+	}
+
+	stream.overflows = map[int]bool{stream.reads + 1: true}
+	err := capture.read(stream, frame)
+	if err != portaudio.InputOverflowed {
+		t.Errorf("read error = %v, want the overflow reported", err)
+	}
+	if readFailed(err) {
+		t.Error("readFailed(InputOverflowed) = true, want overflows to be non-fatal")
+	}
+	for i, sample := range frame {
+		if sample < 0.24 || sample > 0.26 {
+			t.Fatalf("sample %d = %v after an overflow, want a full frame at 0.25", i, sample)
+		}
+	}
+
+	if err := capture.read(stream, frame); err != nil {
+		t.Errorf("read after the overflow error = %v, want nil", err)
+	}
+}
+
+func TestReadFailed(t *testing.T) {
+	if readFailed(nil) {
+		t.Error("readFailed(nil) = true")
+	}
+	if !readFailed(portaudio.StreamIsStopped) {
+		t.Error("readFailed(StreamIsStopped) = false, want other errors to be fatal")
+	}
+}
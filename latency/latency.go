@@ -0,0 +1,88 @@
+// Package latency reports the end-to-end delay of the audio path, from the
+// microphone through the processing pipeline to the output device
+package latency
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/errakhaoui/noise-canceling/dsp"
+)
+
+// Stage is the delay one part of the audio path adds
+type Stage struct {
+	Name    string
+	Latency time.Duration
+}
+
+// Report lists the stages that delay the audio, in signal order
+type Report []Stage
+
+// Streams holds the delays of the audio streams around the pipeline, as
+// opened by PortAudio. They are fixed while the streams run.
+type Streams struct {
+	Input      time.Duration // input device, including any resampling
+	Frame      time.Duration // one frame of buffering
+	Output     time.Duration // output device, including any resampling
+	SampleRate int           // rate the pipeline runs at
+}
+
+// Measure reports the latency of the audio path: the input stream, one
+// frame of buffering, every pipeline stage that delays the audio and the
+// output stream. Stages can change their delay while running, so measure
+// again after changing the pipeline.
+func Measure(streams Streams, pipeline *dsp.Chain) Report {
+	report := Report{
+		{"Input device", streams.Input},
+		{"Frame", streams.Frame},
+	}
+	report = append(report, Pipeline(pipeline, streams.SampleRate)...)
+	return append(report, Stage{"Output device", streams.Output})
+}
+
+// Pipeline reports the declared latency of each stage in pipeline, skipping
+// stages that add none
+func Pipeline(pipeline *dsp.Chain, sampleRate int) Report {
+	var report Report
+	for _, p := range pipeline.Stages() {
+		if samples := p.Latency(); samples > 0 {
+			report = append(report, Stage{stageName(p), time.Duration(samples) * time.Second / time.Duration(sampleRate)})
+		}
+	}
+	return report
+}
+
+// stageName returns the name of a pipeline stage: its String method if it
+// has one, otherwise its type name
+func stageName(p dsp.Processor) string {
+	if s, ok := p.(fmt.Stringer); ok {
+		return s.String()
+	}
+	name := fmt.Sprintf("%T", p)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// Total returns the end-to-end latency
+func (r Report) Total() time.Duration {
+	var total time.Duration
+	for _, stage := range r {
+		total += stage.Latency
+	}
+	return total
+}
+
+// String formats the total followed by the breakdown, e.g.
+// "45.0 ms (Input device 20.0 ms, Frame 10.0 ms, ...)"
+func (r Report) String() string {
+	parts := make([]string, len(r))
+	for i, stage := range r {
+		parts[i] = fmt.Sprintf("%s %s", stage.Name, Milliseconds(stage.Latency))
+	}
+	return fmt.Sprintf("%s (%s)", Milliseconds(r.Total()), strings.Join(parts, ", "))
+}
+
+// Milliseconds formats a latency as milliseconds with one decimal
+func Milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", d.Seconds()*1000)
+}
@@ -0,0 +1,86 @@
+package latency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/errakhaoui/noise-canceling/dsp"
+	"github.com/errakhaoui/noise-canceling/noise_canceller"
+)
+
+func TestPipeline(t *testing.T) {
+	const rate = 48000
+	limiter := dsp.NewLimiter(rate, 1, dsp.DefaultLimiterConfig())
+	pipeline := dsp.NewChain(
+		dsp.NewHighPass(rate, 1, dsp.DefaultHighPassCutoff),
+		noise_canceller.DefaultStage(),
+		dsp.NewAGC(rate, 1, dsp.DefaultAGCConfig(), nil),
+		limiter,
+	)
+
+	report := Pipeline(pipeline, rate)
+	want := Report{
+		{"RNNoise", 10 * time.Millisecond},
+		{"Limiter", time.Duration(limiter.Latency()) * time.Second / rate},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("Pipeline() = %v, want %v", report, want)
+	}
+	for i := range want {
+		if report[i] != want[i] {
+			t.Errorf("stage %d = %+v, want %+v", i, report[i], want[i])
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := Report{
+		{"Input device", 20 * time.Millisecond},
+		{"Frame", 10 * time.Millisecond},
+		{"RNNoise", 10 * time.Millisecond},
+		{"Output device", 5500 * time.Microsecond},
+	}
+	if got, want := report.Total(), 45500*time.Microsecond; got != want {
+		t.Errorf("Total() = %v, want %v", got, want)
+	}
+	want := "45.5 ms (Input device 20.0 ms, Frame 10.0 ms, RNNoise 10.0 ms, Output device 5.5 ms)"
+	if got := report.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	const rate = 48000
+	streams := Streams{
+		Input:      20 * time.Millisecond,
+		Frame:      10 * time.Millisecond,
+		Output:     5 * time.Millisecond,
+		SampleRate: rate,
+	}
+	subtractor := dsp.NewNoiseSubtractor(rate, 1, dsp.DefaultSubtractionConfig())
+	pipeline := dsp.NewChain(dsp.NewHighPass(rate, 1, dsp.DefaultHighPassCutoff), subtractor)
+
+	report := Measure(streams, pipeline)
+	if got, want := report.String(), "35.0 ms (Input device 20.0 ms, Frame 10.0 ms, Output device 5.0 ms)"; got != want {
+		t.Errorf("Measure() = %q, want %q", got, want)
+	}
+
+	// Learning delays the subtractor, which a new measurement picks up
+	subtractor.Learn(time.Second)
+	defer subtractor.CancelLearn()
+	report = Measure(streams, pipeline)
+	want := Report{
+		{"Input device", 20 * time.Millisecond},
+		{"Frame", 10 * time.Millisecond},
+		{"NoiseSubtractor", time.Duration(subtractor.Latency()) * time.Second / rate},
+		{"Output device", 5 * time.Millisecond},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("Measure() while learning = %v, want %v", report, want)
+	}
+	for i := range want {
+		if report[i] != want[i] {
+			t.Errorf("stage %d = %+v, want %+v", i, report[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package noise_canceller
+
+import (
+	"math"
+)
+
+// softClipKnee is the level above which soft clipping starts to compress
+const softClipKnee = 0.8
+
+// isClipping reports whether a normalised sample exceeds full scale
+func isClipping(sample float32) bool {
+	return sample > 1 || sample < -1
+}
+
+// softClip passes samples below the knee unchanged and smoothly compresses
+// louder ones so they approach, but never exceed, full scale
+func softClip(sample float32) float32 {
+	magnitude := math.Abs(float64(sample))
+	if magnitude <= softClipKnee {
+		return sample
+	}
+
+	headroom := 1 - softClipKnee
+	compressed := softClipKnee + headroom*math.Tanh((magnitude-softClipKnee)/headroom)
+	return float32(math.Copysign(compressed, float64(sample)))
+}
+
+// toInt16 converts a normalised sample to int16, saturating at the limits
+// instead of wrapping around
+func toInt16(sample float32) int16 {
+	scaled := float64(sample) * int16Scale
+	if scaled >= math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if scaled <= math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(scaled)
+}
@@ -0,0 +1,107 @@
+package noise_canceller
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToInt16Saturates(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample float32
+		want   int16
+	}{
+		{"Zero", 0, 0},
+		{"Half", 0.5, 16384},
+		{"FullScalePositive", 1, 32767},
+		{"FullScaleNegative", -1, -32768},
+		{"OverPositive", 1.5, 32767},
+		{"OverNegative", -3, -32768},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toInt16(tt.sample); got != tt.want {
+				t.Errorf("toInt16(%v) = %d, want %d", tt.sample, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSoftClip(t *testing.T) {
+	t.Run("UnchangedBelowKnee", func(t *testing.T) {
+		for _, sample := range []float32{0, 0.3, -0.5, softClipKnee, -softClipKnee} {
+			if got := softClip(sample); got != sample {
+				t.Errorf("softClip(%v) = %v, want unchanged", sample, got)
+			}
+		}
+	})
+
+	t.Run("BoundedAndMonotonic", func(t *testing.T) {
+		prev := softClip(-4)
+		for x := -4.0; x <= 4; x += 0.001 {
+			y := softClip(float32(x))
+			if y > 1 || y < -1 {
+				t.Fatalf("softClip(%v) = %v exceeds full scale", x, y)
+			}
+			if y < prev {
+				t.Fatalf("softClip not monotonic at %v", x)
+			}
+			prev = y
+		}
+	})
+
+	t.Run("ContinuousAtKnee", func(t *testing.T) {
+		above := softClip(softClipKnee + 1e-4)
+		if math.Abs(float64(above-softClipKnee)) > 2e-4 {
+			t.Errorf("softClip jumps at knee: %v", above)
+		}
+	})
+}
+
+func TestClipCounter(t *testing.T) {
+	d := New()
+	defer d.Close()
+	d.SetStrength(0) // Output the dry signal so levels are predictable
+
+	hot := make([]float32, frameSize)
+	for i := range hot {
+		hot[i] = 1.5
+	}
+	d.ProcessFloat32(hot)
+
+	// The hot frame reaches the output one frame later
+	result := d.ProcessFloat32(make([]float32, frameSize))
+	if result.ClippedSamples != frameSize {
+		t.Errorf("ClippedSamples = %d, want %d", result.ClippedSamples, frameSize)
+	}
+	if d.ClipCount() != frameSize {
+		t.Errorf("ClipCount() = %d, want %d", d.ClipCount(), frameSize)
+	}
+
+	d.ResetClipCount()
+	if d.ClipCount() != 0 {
+		t.Errorf("ClipCount() = %d after reset, want 0", d.ClipCount())
+	}
+}
+
+func TestSoftClipOutput(t *testing.T) {
+	d := New()
+	defer d.Close()
+	d.SetStrength(0)
+	d.SetSoftClip(true)
+
+	hot := make([]float32, frameSize)
+	for i := range hot {
+		hot[i] = 1.5
+	}
+	d.ProcessFloat32(hot)
+
+	out := make([]float32, frameSize)
+	d.ProcessFloat32(out)
+	for i, sample := range out {
+		if sample > 1 || sample <= softClipKnee {
+			t.Fatalf("sample %d = %v, want soft-clipped between knee and full scale", i, sample)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package noise_canceller
+
+import "time"
+
+// DefaultCrossfade is how long toggling noise cancellation takes to fade
+// between the denoised and original audio
+const DefaultCrossfade = 20 * time.Millisecond
+
+// crossfade blends processed audio with delay-compensated dry audio. The mix
+// moves gradually towards its target so toggling doesn't click.
+type crossfade struct {
+	mix float32 // 0 = dry only, 1 = processed only
+}
+
+// apply blends the interleaved processed frame (in place) with dry, moving
+// the mix towards target by step per sample frame. It returns false when the
+// output is entirely dry.
+func (c *crossfade) apply(frame, dry []float32, channels int, target, step float32) bool {
+	if c.mix == target {
+		if target == 0 {
+			copy(frame, dry)
+			return false
+		}
+		if target == 1 {
+			return true
+		}
+	}
+
+	wet := false
+	for i := 0; i < len(frame); i += channels {
+		if c.mix < target {
+			c.mix = min(target, c.mix+step)
+		} else {
+			c.mix = max(target, c.mix-step)
+		}
+		wet = wet || c.mix > 0
+		for j := i; j < i+channels; j++ {
+			frame[j] = c.mix*frame[j] + (1-c.mix)*dry[j]
+		}
+	}
+	return wet
+}
+
+// fadeTarget returns the mix the Denoiser's crossfade should move towards
+func (d *Denoiser) fadeTarget() float32 {
+	if d.enabled.Load() {
+		return 1
+	}
+	return 0
+}
+
+// fadeStep returns how far the mix moves per sample
+func (d *Denoiser) fadeStep() float32 {
+	samples := d.fadeSamples.Load()
+	if samples <= 0 {
+		return 1
+	}
+	return 1 / float32(samples)
+}
+
+// SetCrossfade sets how long toggling noise cancellation takes to fade
+// between denoised and original audio (0 switches instantly)
+func (d *Denoiser) SetCrossfade(duration time.Duration) {
+	d.fadeSamples.Store(int32(max(0, duration.Seconds()*sampleRate)))
+}
+
+// Crossfade returns the toggle crossfade duration
+func (d *Denoiser) Crossfade() time.Duration {
+	return time.Duration(d.fadeSamples.Load()) * time.Second / sampleRate
+}
+
+// SetCrossfade sets the toggle crossfade duration of the default Denoiser
+func SetCrossfade(duration time.Duration) {
+	defaultDenoiser.SetCrossfade(duration)
+}
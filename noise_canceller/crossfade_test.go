@@ -0,0 +1,139 @@
+package noise_canceller
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// toggleSignal returns a quiet tone over noise-like content, low enough that
+// nothing clips
+func toggleSignal(frames int) []float32 {
+	audio := make([]float32, frames*frameSize)
+	for i := range audio {
+		audio[i] = 0.2*float32(math.Sin(2*math.Pi*300*float64(i)/sampleRate)) + float32((i*37)%2000-1000)/10000
+	}
+	return audio
+}
+
+func TestCrossfadeOnToggle(t *testing.T) {
+	// reference stays enabled; its output is what the toggled Denoiser's
+	// warm RNNoise state must produce
+	reference := New()
+	defer reference.Close()
+	d := New()
+	defer d.Close()
+	d.SetCrossfade(5 * time.Millisecond)
+	fadeSamples := 240
+
+	input := toggleSignal(12)
+	mix := float32(1)
+	for f := 0; f < 12; f++ {
+		switch f {
+		case 3:
+			d.Disable()
+		case 7:
+			d.Enable()
+		}
+
+		frame := input[f*frameSize : (f+1)*frameSize]
+		wet := append([]float32(nil), frame...)
+		reference.ProcessFloat32(wet)
+		out := append([]float32(nil), frame...)
+		result := d.ProcessFloat32(out)
+
+		target := float32(0)
+		if d.IsEnabled() {
+			target = 1
+		}
+		for i := range out {
+			var dry float32
+			if f > 0 {
+				dry = input[(f-1)*frameSize+i]
+			}
+			if mix < target {
+				mix = min(target, mix+1/float32(fadeSamples))
+			} else {
+				mix = max(target, mix-1/float32(fadeSamples))
+			}
+			want := mix*wet[i] + (1-mix)*dry
+			if math.Abs(float64(out[i]-want)) > 1e-5 {
+				t.Fatalf("frame %d sample %d = %v, want %v (mix %.3f)", f, i, out[i], want, mix)
+			}
+		}
+
+		if wantProcessed := f < 4 || f >= 7; result.Processed != wantProcessed {
+			t.Errorf("frame %d Processed = %v, want %v", f, result.Processed, wantProcessed)
+		}
+	}
+}
+
+func TestCrossfadeDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		want     time.Duration
+	}{
+		{"Default", DefaultCrossfade, DefaultCrossfade},
+		{"Custom", 50 * time.Millisecond, 50 * time.Millisecond},
+		{"Instant", 0, 0},
+		{"Negative", -time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New()
+			defer d.Close()
+			d.SetCrossfade(tt.duration)
+			if got := d.Crossfade(); got != tt.want {
+				t.Errorf("Crossfade() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstantToggle(t *testing.T) {
+	d := New()
+	defer d.Close()
+	d.SetCrossfade(0)
+
+	input := toggleSignal(2)
+	d.ProcessFloat32(append([]float32(nil), input[:frameSize]...))
+	d.Disable()
+	out := append([]float32(nil), input[frameSize:]...)
+	if result := d.ProcessFloat32(out); result.Processed {
+		t.Error("first frame after an instant toggle contains denoised audio")
+	}
+	for i, sample := range out {
+		if sample != input[i] {
+			t.Fatalf("sample %d = %v, want the previous frame's %v", i, sample, input[i])
+		}
+	}
+}
+
+func TestMultiDenoiserBypass(t *testing.T) {
+	for _, mode := range []ChannelMode{Independent, Linked} {
+		t.Run(mode.String(), func(t *testing.T) {
+			d := New()
+			defer d.Close()
+			d.Disable()
+			m := newMulti(t, d, 2, mode)
+			defer m.Close()
+
+			input := stereoFrames(4)
+			output := append([]float32(nil), input...)
+			step := frameSize * 2
+			for start := 0; start < len(output); start += step {
+				if result := m.ProcessFloat32(output[start : start+step]); result.Processed {
+					t.Fatalf("frame at %d processed while disabled", start)
+				}
+			}
+
+			for i := step; i < len(output); i++ {
+				if output[i] != input[i-step] {
+					t.Fatalf("sample %d (channel %d) = %v, want %v", i, i%2, output[i], input[i-step])
+				}
+			}
+		})
+	}
+}
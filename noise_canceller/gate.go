@@ -0,0 +1,122 @@
+package noise_canceller
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const sampleRate = 48000
+
+// GateConfig configures the voice-activity driven noise gate
+type GateConfig struct {
+	// Threshold is the voice probability (0-1) below which the gate closes.
+	// A threshold of 0 disables the gate.
+	Threshold float32
+	// Attack is how long the gate takes to open fully once speech is detected
+	Attack time.Duration
+	// Hold keeps the gate open after speech stops so word endings aren't cut
+	Hold time.Duration
+	// Release is how long the gate takes to close fully after the hold period
+	Release time.Duration
+	// AttenuationDB is how far the signal is reduced when the gate is closed
+	AttenuationDB float32
+}
+
+// DefaultGateConfig returns gate timings tuned for speech (gate disabled)
+func DefaultGateConfig() GateConfig {
+	return GateConfig{
+		Threshold:     0,
+		Attack:        5 * time.Millisecond,
+		Hold:          200 * time.Millisecond,
+		Release:       150 * time.Millisecond,
+		AttenuationDB: 40,
+	}
+}
+
+// Gate attenuates audio while the voice probability stays below a threshold,
+// ramping its gain smoothly so words are not clipped
+type Gate struct {
+	threshold   atomic.Uint32 // float32 bits, adjustable while audio is running
+	floor       float32
+	attackStep  float32
+	releaseStep float32
+	holdSamples int
+
+	gain          float32
+	holdRemaining int
+}
+
+// NewGate creates a gate from the given configuration
+func NewGate(cfg GateConfig) *Gate {
+	g := &Gate{
+		floor:       float32(math.Pow(10, -float64(cfg.AttenuationDB)/20)),
+		holdSamples: durationToSamples(cfg.Hold),
+		gain:        1,
+	}
+	g.attackStep = rampStep(g.floor, cfg.Attack)
+	g.releaseStep = rampStep(g.floor, cfg.Release)
+	g.SetThreshold(cfg.Threshold)
+	return g
+}
+
+// SetThreshold changes the voice probability threshold (0 disables the gate)
+func (g *Gate) SetThreshold(threshold float32) {
+	threshold = float32(math.Max(0, math.Min(1, float64(threshold))))
+	g.threshold.Store(math.Float32bits(threshold))
+}
+
+// Threshold returns the current voice probability threshold
+func (g *Gate) Threshold() float32 {
+	return math.Float32frombits(g.threshold.Load())
+}
+
+// Reset opens the gate fully and clears the hold timer
+func (g *Gate) Reset() {
+	g.gain = 1
+	g.holdRemaining = 0
+}
+
+// Apply gates one frame in place based on its voice probability and returns
+// the gain reached at the end of the frame
+func (g *Gate) Apply(frame []float32, voiceProbability float32) float32 {
+	threshold := g.Threshold()
+	if threshold <= 0 {
+		g.gain = 1
+		g.holdRemaining = 0
+		return g.gain
+	}
+
+	if voiceProbability >= threshold {
+		g.holdRemaining = g.holdSamples
+	}
+
+	for i := range frame {
+		if voiceProbability >= threshold || g.holdRemaining > 0 {
+			if g.holdRemaining > 0 && voiceProbability < threshold {
+				g.holdRemaining--
+			}
+			g.gain = float32(math.Min(1, float64(g.gain+g.attackStep)))
+		} else {
+			g.gain = float32(math.Max(float64(g.floor), float64(g.gain-g.releaseStep)))
+		}
+		frame[i] *= g.gain
+	}
+
+	return g.gain
+}
+
+// durationToSamples converts a duration to a sample count at 48 kHz
+func durationToSamples(d time.Duration) int {
+	return int(d.Seconds() * sampleRate)
+}
+
+// rampStep returns the per-sample gain change needed to move between floor
+// and unity over the given duration
+func rampStep(floor float32, d time.Duration) float32 {
+	samples := durationToSamples(d)
+	if samples < 1 {
+		return 1
+	}
+	return (1 - floor) / float32(samples)
+}
@@ -0,0 +1,105 @@
+package noise_canceller
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func constantFrame(value float32) []float32 {
+	frame := make([]float32, frameSize)
+	for i := range frame {
+		frame[i] = value
+	}
+	return frame
+}
+
+func TestGateDisabled(t *testing.T) {
+	t.Run("ZeroThresholdPassesAudio", func(t *testing.T) {
+		gate := NewGate(DefaultGateConfig())
+
+		frame := constantFrame(0.3)
+		gain := gate.Apply(frame, 0)
+
+		if gain != 1 {
+			t.Errorf("gain = %v, want 1", gain)
+		}
+		for i, s := range frame {
+			if s != 0.3 {
+				t.Fatalf("sample %d modified: got %v, want 0.3", i, s)
+			}
+		}
+	})
+}
+
+func TestGateClosesOnSilence(t *testing.T) {
+	cfg := DefaultGateConfig()
+	cfg.Threshold = 0.5
+	gate := NewGate(cfg)
+
+	// Hold (200ms) + release (150ms) is 35 frames; run well past it
+	var gain float32
+	for i := 0; i < 50; i++ {
+		gain = gate.Apply(constantFrame(0.3), 0.1)
+	}
+
+	floor := float32(math.Pow(10, -float64(cfg.AttenuationDB)/20))
+	if math.Abs(float64(gain-floor)) > 1e-6 {
+		t.Errorf("gain = %v, want floor %v", gain, floor)
+	}
+}
+
+func TestGateHoldKeepsGateOpen(t *testing.T) {
+	cfg := DefaultGateConfig()
+	cfg.Threshold = 0.5
+	gate := NewGate(cfg)
+
+	gate.Apply(constantFrame(0.3), 0.9)
+
+	// 100ms of non-speech is still within the 200ms hold period
+	holdFrames := int(100 * time.Millisecond / (10 * time.Millisecond))
+	for i := 0; i < holdFrames; i++ {
+		if gain := gate.Apply(constantFrame(0.3), 0.1); gain != 1 {
+			t.Fatalf("frame %d: gain = %v during hold, want 1", i, gain)
+		}
+	}
+}
+
+func TestGateRampsWithoutJumps(t *testing.T) {
+	cfg := DefaultGateConfig()
+	cfg.Threshold = 0.5
+	gate := NewGate(cfg)
+
+	// Close the gate, then reopen it and check the ramp is gradual
+	for i := 0; i < 50; i++ {
+		gate.Apply(constantFrame(0.3), 0)
+	}
+
+	frame := constantFrame(0.3)
+	gate.Apply(frame, 1)
+
+	maxStep := 0.3 * gate.attackStep * 1.01
+	for i := 1; i < len(frame); i++ {
+		step := frame[i] - frame[i-1]
+		if step < 0 || step > maxStep {
+			t.Fatalf("sample %d: jump of %v exceeds attack ramp %v", i, step, maxStep)
+		}
+	}
+	if frame[len(frame)-1] != 0.3 {
+		t.Errorf("gate did not fully open within attack time: last sample %v", frame[len(frame)-1])
+	}
+}
+
+func TestGateThresholdClamped(t *testing.T) {
+	gate := NewGate(DefaultGateConfig())
+
+	gate.SetThreshold(2)
+	if gate.Threshold() != 1 {
+		t.Errorf("Threshold() = %v, want 1", gate.Threshold())
+	}
+
+	gate.SetThreshold(-1)
+	if gate.Threshold() != 0 {
+		t.Errorf("Threshold() = %v, want 0", gate.Threshold())
+	}
+}
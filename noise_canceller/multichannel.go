@@ -0,0 +1,303 @@
+package noise_canceller
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ChannelMode selects how the channels of multi-channel audio are denoised
+type ChannelMode int
+
+const (
+	// Independent runs a separate RNNoise state on every channel
+	Independent ChannelMode = iota
+	// Linked denoises the mid (average) signal and applies the same
+	// suppression to every channel, keeping the stereo image stable
+	Linked
+)
+
+// String returns the mode's name as accepted by ParseChannelMode
+func (m ChannelMode) String() string {
+	switch m {
+	case Independent:
+		return "independent"
+	case Linked:
+		return "linked"
+	default:
+		return fmt.Sprintf("ChannelMode(%d)", int(m))
+	}
+}
+
+// ParseChannelMode converts "independent" or "linked" to a ChannelMode
+func ParseChannelMode(name string) (ChannelMode, error) {
+	switch strings.ToLower(name) {
+	case "independent":
+		return Independent, nil
+	case "linked":
+		return Linked, nil
+	default:
+		return Independent, fmt.Errorf("unknown channel mode %q (expected independent or linked)", name)
+	}
+}
+
+// MultiDenoiser denoises interleaved multi-channel frames. Settings such as
+// strength, gate threshold, soft clipping and the model are taken from the
+// primary Denoiser, so the package-level setters keep working.
+type MultiDenoiser struct {
+	primary  *Denoiser
+	channels int
+	mode     ChannelMode
+
+	// secondaries denoise channels 1..n-1 in Independent mode
+	secondaries []*Denoiser
+	// planar holds each channel of the current frame (Independent mode)
+	planar [][frameSize]float32
+	// side holds each channel's delayed difference from the mid signal
+	// (Linked mode)
+	side [][rnnoiseDelay]float32
+	// mid holds the downmixed frame (Linked mode)
+	mid [frameSize]float32
+	// residualGain is the suppression applied to the side signals at the end
+	// of the last frame (Linked mode)
+	residualGain float32
+
+	// previous holds the last interleaved input frame and bypass the one
+	// before it, which lines up with the denoised output
+	previous, bypass []float32
+	fade             crossfade
+}
+
+// NewMultiDenoiser creates a MultiDenoiser for frames of the given channel
+// count, driven by primary. The primary Denoiser stays owned by the caller.
+// In Independent mode every extra channel gets its own RNNoise state using
+// the primary's model, which fails if the model file can no longer be read.
+func NewMultiDenoiser(primary *Denoiser, channels int, mode ChannelMode) (*MultiDenoiser, error) {
+	if channels < 1 {
+		channels = 1
+	}
+
+	m := &MultiDenoiser{primary: primary, channels: channels, mode: mode, residualGain: 1}
+	if channels == 1 {
+		return m, nil
+	}
+
+	m.previous = make([]float32, frameSize*channels)
+	m.bypass = make([]float32, frameSize*channels)
+	m.fade.mix = primary.fadeTarget()
+
+	switch mode {
+	case Linked:
+		m.side = make([][rnnoiseDelay]float32, channels)
+	default:
+		m.planar = make([][frameSize]float32, channels)
+		for c := 1; c < channels; c++ {
+			d, err := NewWithModel(primary.modelPath)
+			if err != nil {
+				m.Close()
+				return nil, fmt.Errorf("error creating denoiser for channel %d: %w", c, err)
+			}
+			m.secondaries = append(m.secondaries, d)
+		}
+	}
+	return m, nil
+}
+
+// LoadModel switches every channel to the RNNoise model at path (empty path
+// restores the built-in model). On error the current model is kept. Must not
+// be called while audio is being processed.
+func (m *MultiDenoiser) LoadModel(path string) error {
+	previous := m.primary.modelPath
+	for i, d := range m.secondaries {
+		if err := d.LoadModel(path); err != nil {
+			// The previous model loaded before, so restoring it succeeds
+			for _, loaded := range m.secondaries[:i] {
+				_ = loaded.LoadModel(previous)
+			}
+			return err
+		}
+	}
+	if err := m.primary.LoadModel(path); err != nil {
+		for _, d := range m.secondaries {
+			_ = d.LoadModel(previous)
+		}
+		return err
+	}
+	return nil
+}
+
+// Channels returns the number of interleaved channels per frame
+func (m *MultiDenoiser) Channels() int {
+	return m.channels
+}
+
+// Mode returns how channels are denoised
+func (m *MultiDenoiser) Mode() ChannelMode {
+	return m.mode
+}
+
+// ProcessFloat32 denoises one interleaved frame of FrameSize samples per
+// channel in place. Frames of other lengths are left untouched. The result
+// reports the highest voice probability across channels.
+func (m *MultiDenoiser) ProcessFloat32(frame []float32) FrameResult {
+	if m.channels == 1 {
+		return m.primary.ProcessFloat32(frame)
+	}
+
+	d := m.primary
+	if d.engine == nil || len(frame) != frameSize*m.channels {
+		return FrameResult{GateGain: 1}
+	}
+
+	copy(m.bypass, m.previous)
+	copy(m.previous, frame)
+
+	var vad, gain float32
+	if m.mode == Linked {
+		vad, gain = m.processLinked(frame)
+	} else {
+		vad, gain = m.processIndependent(frame)
+	}
+
+	if !m.fade.apply(frame, m.bypass, m.channels, d.fadeTarget(), d.fadeStep()) {
+		return FrameResult{GateGain: 1}
+	}
+	clipped := d.limit(frame)
+	return FrameResult{VoiceProbability: vad, Processed: true, GateGain: gain, ClippedSamples: clipped}
+}
+
+// processIndependent denoises every channel with its own RNNoise state
+func (m *MultiDenoiser) processIndependent(frame []float32) (float32, float32) {
+	for i, sample := range frame {
+		m.planar[i%m.channels][i/m.channels] = sample
+	}
+
+	var maxVAD, maxGain float32
+	for c := range m.planar {
+		d := m.primary
+		if c > 0 {
+			d = m.secondaries[c-1]
+			m.sync(d)
+		}
+
+		vad, gain := d.denoise(m.planar[c][:])
+		maxVAD = max(maxVAD, vad)
+		maxGain = max(maxGain, gain)
+	}
+
+	for i := range frame {
+		frame[i] = m.planar[i%m.channels][i/m.channels]
+	}
+	return maxVAD, maxGain
+}
+
+// processLinked denoises the mid signal and scales each channel's difference
+// from it by the suppression RNNoise applied to the mid
+func (m *MultiDenoiser) processLinked(frame []float32) (float32, float32) {
+	scale := 1 / float32(m.channels)
+	for i := range m.mid {
+		var sum float32
+		for c := 0; c < m.channels; c++ {
+			sum += frame[i*m.channels+c]
+		}
+		m.mid[i] = sum * scale
+	}
+
+	// The primary's dry buffer holds the previous mid frame, which lines up
+	// with the denoised output
+	var before float64
+	for _, sample := range m.primary.dry {
+		before += float64(sample) * float64(sample)
+	}
+
+	// Remember each channel's residual before the mid is denoised in place
+	for i := range m.mid {
+		for c := 0; c < m.channels; c++ {
+			idx := i*m.channels + c
+			residual := frame[idx] - m.mid[i]
+			frame[idx] = m.side[c][i]
+			m.side[c][i] = residual
+		}
+	}
+
+	vad, gain := m.primary.denoise(m.mid[:])
+
+	var after float64
+	for _, sample := range m.mid {
+		after += float64(sample) * float64(sample)
+	}
+
+	// Suppression never amplifies the residual
+	target := float32(1)
+	if before > 1e-12 {
+		target = float32(math.Min(1, math.Sqrt(after/before)))
+	}
+
+	// Ramp from the last frame's gain so it doesn't step at frame boundaries
+	start := m.residualGain
+	step := (target - start) / frameSize
+	for i, sample := range m.mid {
+		residualGain := start + step*float32(i+1)
+		for c := 0; c < m.channels; c++ {
+			idx := i*m.channels + c
+			frame[idx] = sample + residualGain*frame[idx]
+		}
+	}
+	m.residualGain = target
+	return vad, gain
+}
+
+// sync copies the primary's settings to a secondary Denoiser. The model is
+// kept in step by LoadModel, off the audio path.
+func (m *MultiDenoiser) sync(d *Denoiser) {
+	d.SetStrength(m.primary.Strength())
+	d.gate.SetThreshold(m.primary.gate.Threshold())
+}
+
+// Reset clears the state of every channel
+func (m *MultiDenoiser) Reset() {
+	m.primary.Reset()
+	for _, d := range m.secondaries {
+		d.Reset()
+	}
+	for c := range m.side {
+		m.side[c] = [rnnoiseDelay]float32{}
+	}
+	clear(m.previous)
+	clear(m.bypass)
+	m.residualGain = 1
+	m.fade.mix = m.primary.fadeTarget()
+}
+
+// Close destroys the per-channel RNNoise states; the primary Denoiser is
+// left open
+func (m *MultiDenoiser) Close() {
+	for _, d := range m.secondaries {
+		d.Close()
+	}
+	m.secondaries = nil
+}
+
+// SetChannels configures the channel count and mode used by
+// ExecuteInterleavedFloat32. On error the previous layout is kept. Must not
+// be called while audio is being processed.
+func SetChannels(channels int, mode ChannelMode) error {
+	m, err := NewMultiDenoiser(defaultDenoiser, channels, mode)
+	if err != nil {
+		return err
+	}
+	defaultMulti.Close()
+	defaultMulti = m
+	return nil
+}
+
+// Channels returns the channel count used by ExecuteInterleavedFloat32
+func Channels() int {
+	return defaultMulti.Channels()
+}
+
+// ExecuteInterleavedFloat32 denoises one interleaved float32 frame in place
+// using the default Denoiser and the channel layout set by SetChannels
+func ExecuteInterleavedFloat32(frame []float32) FrameResult {
+	return defaultMulti.ProcessFloat32(frame)
+}
@@ -0,0 +1,195 @@
+package noise_canceller
+
+import (
+	"math"
+	"testing"
+)
+
+// stereoFrames returns interleaved stereo frames with different content on
+// each channel
+func stereoFrames(frames int) []float32 {
+	audio := make([]float32, frames*frameSize*2)
+	for i := 0; i < frames*frameSize; i++ {
+		audio[i*2] = float32(math.Sin(2*math.Pi*440*float64(i)/sampleRate)) * 0.5
+		audio[i*2+1] = float32((i*37)%2000-1000) / 4000
+	}
+	return audio
+}
+
+// newMulti creates a MultiDenoiser, failing the test on error
+func newMulti(tb testing.TB, primary *Denoiser, channels int, mode ChannelMode) *MultiDenoiser {
+	tb.Helper()
+	m, err := NewMultiDenoiser(primary, channels, mode)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+func TestMultiDenoiserDryDelay(t *testing.T) {
+	for _, mode := range []ChannelMode{Independent, Linked} {
+		t.Run(mode.String(), func(t *testing.T) {
+			d := New()
+			defer d.Close()
+			// With zero strength both modes must reproduce every channel
+			// delayed by one frame
+			d.SetStrength(0)
+			m := newMulti(t, d, 2, mode)
+			defer m.Close()
+
+			input := stereoFrames(6)
+			output := make([]float32, len(input))
+			copy(output, input)
+
+			step := frameSize * 2
+			for start := 0; start < len(output); start += step {
+				result := m.ProcessFloat32(output[start : start+step])
+				if !result.Processed {
+					t.Fatalf("frame at %d was not processed", start)
+				}
+			}
+
+			delay := d.Latency() * 2
+			for i := range output {
+				want := float32(0)
+				if i >= delay {
+					want = input[i-delay]
+				}
+				if math.Abs(float64(output[i]-want)) > 1e-6 {
+					t.Fatalf("sample %d (channel %d) = %v, want %v", i, i%2, output[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiDenoiserChannelStates(t *testing.T) {
+	d := New()
+	defer d.Close()
+	m := newMulti(t, d, 3, Independent)
+	defer m.Close()
+
+	if len(m.secondaries) != 2 {
+		t.Fatalf("got %d secondary states, want 2", len(m.secondaries))
+	}
+	for _, s := range m.secondaries {
+		if s.engine == nil || s.engine == d.engine {
+			t.Fatal("each channel needs its own RNNoise state")
+		}
+	}
+
+	// Settings follow the primary Denoiser
+	d.SetStrength(0.25)
+	d.SetGateThreshold(0.5)
+	m.ProcessFloat32(make([]float32, frameSize*3))
+	for _, s := range m.secondaries {
+		if s.Strength() != 0.25 || s.gate.Threshold() != 0.5 {
+			t.Errorf("secondary settings = %v/%v, want 0.25/0.5", s.Strength(), s.gate.Threshold())
+		}
+	}
+}
+
+func TestMultiDenoiserFrameLength(t *testing.T) {
+	d := New()
+	defer d.Close()
+	m := newMulti(t, d, 2, Linked)
+	defer m.Close()
+
+	frame := make([]float32, frameSize) // mono-sized frame is wrong for stereo
+	for i := range frame {
+		frame[i] = 0.5
+	}
+	if result := m.ProcessFloat32(frame); result.Processed {
+		t.Error("frame of the wrong length was processed")
+	}
+	for i, sample := range frame {
+		if sample != 0.5 {
+			t.Fatalf("sample %d modified to %v", i, sample)
+		}
+	}
+}
+
+// TestMultiDenoiserLinkedGainRamps checks that a change in the suppression
+// applied to the side signals is spread over the frame instead of stepping
+func TestMultiDenoiserLinkedGainRamps(t *testing.T) {
+	d := New()
+	defer d.Close()
+	m := newMulti(t, d, 2, Linked)
+	defer m.Close()
+
+	// Opposite channels have a silent mid, so the side gain heads back to 1
+	frame := make([]float32, frameSize*2)
+	fill := func() {
+		for i := 0; i < frameSize; i++ {
+			frame[2*i], frame[2*i+1] = 0.5, -0.5
+		}
+	}
+	fill()
+	m.ProcessFloat32(frame)
+	m.residualGain = 0.2 // As if the last frame was heavily suppressed
+	fill()
+	m.ProcessFloat32(frame)
+
+	maxStep := float32(0.8*0.5/frameSize) * 1.01
+	if first := frame[0]; first > 0.2*0.5+maxStep {
+		t.Errorf("first sample = %v, want the ramp to start near the last gain (%v)", first, 0.2*0.5)
+	}
+	for i := 2; i < len(frame); i += 2 {
+		if step := frame[i] - frame[i-2]; step < 0 || step > maxStep {
+			t.Fatalf("sample %d steps by %v, want a ramp of at most %v per sample", i/2, step, maxStep)
+		}
+	}
+	if last := frame[len(frame)-2]; math.Abs(float64(last)-0.5) > 1e-4 {
+		t.Errorf("last sample = %v, want the full side signal 0.5", last)
+	}
+}
+
+func TestParseChannelMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ChannelMode
+		wantErr bool
+	}{
+		{"independent", Independent, false},
+		{"Linked", Linked, false},
+		{"surround", Independent, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChannelMode(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChannelMode(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseChannelMode(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultiDenoiserLoadModelError(t *testing.T) {
+	d := New()
+	defer d.Close()
+	m := newMulti(t, d, 3, Independent)
+	defer m.Close()
+
+	if err := m.LoadModel("missing.rnnn"); err == nil {
+		t.Fatal("LoadModel of a missing file succeeded, want error")
+	}
+	if d.ModelPath() != "" {
+		t.Errorf("primary model = %q after a failed load, want the built-in model", d.ModelPath())
+	}
+	for c, secondary := range m.secondaries {
+		if secondary.ModelPath() != "" || secondary.engine == nil {
+			t.Errorf("channel %d model = %q after a failed load, want the built-in model", c+1, secondary.ModelPath())
+		}
+	}
+
+	// Frames are still denoised on every channel
+	frame := stereoFrames(1)[:frameSize*2]
+	frame = append(frame, frame[:frameSize]...)
+	if result := m.ProcessFloat32(frame); !result.Processed {
+		t.Error("frame not processed after a failed model load")
+	}
+}
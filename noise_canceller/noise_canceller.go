@@ -1,80 +1,384 @@
 package noise_canceller
 
-/*
-#cgo LDFLAGS: -lrnnoise
-#include <stdio.h>
-#include <stdlib.h>
-#include <rnnoise.h>
-*/
-import "C"
 import (
+	"math"
 	"sync/atomic"
-	"unsafe"
 )
 
-var den *C.DenoiseState
-var enabled atomic.Bool
-
 const frameSize = 480
 
+// FrameSize is the number of samples Process expects per frame (10ms at
+// 48 kHz); use a Stream for buffers of other lengths
+const FrameSize = frameSize
+
+// int16Scale converts between normalised float32 samples and the int16 range
+// RNNoise was trained on
+const int16Scale = 32768
+
+// rnnoiseDelay is how many samples the engine's output lags its input (one
+// frame, due to the overlapping analysis window). The pure-Go engine uses the
+// same hop and window so both builds have identical timing.
+const rnnoiseDelay = frameSize
+
+// FrameResult describes the outcome of processing a single frame
+type FrameResult struct {
+	// VoiceProbability is RNNoise's estimate (0-1) that the frame contains speech
+	VoiceProbability float32
+	// Processed reports whether the output contains denoised audio; it is
+	// false once a disabled Denoiser has faded to the bypass signal
+	Processed bool
+	// GateGain is the noise gate gain at the end of the frame (1 = fully open)
+	GateGain float32
+	// ClippedSamples counts output samples that exceeded full scale
+	ClippedSamples int
+}
+
+// Denoiser owns an independent RNNoise state, so several streams can be
+// denoised concurrently without sharing recurrent state. Builds without cgo
+// (or with the purego tag) use a pure-Go spectral suppressor instead.
+//
+// RNNoise keeps running while noise cancellation is disabled, so its state
+// stays warm, and the bypass signal is delayed to match the denoised output.
+// Toggling crossfades between the two without a click or latency jump.
+type Denoiser struct {
+	engine      *engine // nil once closed
+	modelPath   string
+	enabled     atomic.Bool
+	fadeSamples atomic.Int32
+	fade        crossfade
+	strength    atomic.Uint32 // float32 bits, 0 = dry only, 1 = fully denoised
+	gate        *Gate
+	softClip    atomic.Bool
+	clips       atomic.Uint64 // total output samples that exceeded full scale
+
+	// dry holds the previous input frame so the unprocessed signal can be
+	// mixed in aligned with the delayed RNNoise output
+	dry [rnnoiseDelay]float32
+	// scratch holds int16 frames converted to float32 for processing
+	scratch [frameSize]float32
+	// wet holds the engine's output for the current frame
+	wet [frameSize]float32
+	// bypass holds the delayed dry frame while the current one is processed
+	bypass [frameSize]float32
+}
+
+// defaultDenoiser backs the package-level functions
+var defaultDenoiser *Denoiser
+
+// defaultMulti wraps the default Denoiser for interleaved audio
+var defaultMulti *MultiDenoiser
+
 func init() {
-	den = C.rnnoise_create(nil)
-	enabled.Store(true) // Start with noise cancellation enabled
+	defaultDenoiser = New()
+	defaultMulti, _ = NewMultiDenoiser(defaultDenoiser, 1, Independent) // Can't fail with one channel
 }
 
-func Execute(inputAudio []int16) {
-	// Only process if noise cancellation is enabled
-	if !enabled.Load() {
-		return
+// New creates a Denoiser with its own RNNoise state (enabled by default)
+func New() *Denoiser {
+	e, err := newEngine("")
+	if err != nil {
+		panic(err) // The built-in model is always available
+	}
+
+	d := &Denoiser{
+		engine: e,
+		gate:   NewGate(DefaultGateConfig()),
 	}
+	d.enabled.Store(true) // Start with noise cancellation enabled
+	d.fade.mix = 1
+	d.SetStrength(1)
+	d.SetCrossfade(DefaultCrossfade)
+	return d
+}
 
-	inFloat := make([]C.float, frameSize)
-	for i := range inputAudio {
-		inFloat[i] = C.float(inputAudio[i])
+// NewWithModel creates a Denoiser using the RNNoise model stored at path.
+// An empty path uses the built-in model.
+func NewWithModel(path string) (*Denoiser, error) {
+	d := New()
+	if err := d.LoadModel(path); err != nil {
+		d.Close()
+		return nil, err
 	}
+	return d, nil
+}
+
+// LoadModel replaces the RNNoise state with one created from the model file
+// at path (empty path restores the built-in model). On error the current
+// state is kept. Must not be called while Process is running.
+func (d *Denoiser) LoadModel(path string) error {
+	e, err := newEngine(path)
+	if err != nil {
+		return err
+	}
+
+	d.Close()
+	d.engine = e
+	d.modelPath = path
+	d.dry = [rnnoiseDelay]float32{}
+	return nil
+}
+
+// Process denoises one frame of audio in place and reports the frame's
+// voice activity probability. Frames must be exactly FrameSize samples;
+// other lengths are left untouched.
+func (d *Denoiser) Process(inputAudio []int16) FrameResult {
+	if d.engine == nil || len(inputAudio) != frameSize {
+		return FrameResult{GateGain: 1}
+	}
+
+	for i, sample := range inputAudio {
+		d.scratch[i] = float32(sample) / int16Scale
+	}
+
+	result := d.ProcessFloat32(d.scratch[:])
+
+	// Convert float samples back to int16, saturating instead of wrapping
+	for i, sample := range d.scratch {
+		inputAudio[i] = toInt16(sample)
+	}
+
+	return result
+}
+
+// ProcessFloat32 denoises one frame of normalised (-1 to 1) float32 audio in
+// place. Frames must be exactly FrameSize samples; other lengths are left
+// untouched.
+func (d *Denoiser) ProcessFloat32(frame []float32) FrameResult {
+	if d.engine == nil || len(frame) != frameSize {
+		return FrameResult{GateGain: 1}
+	}
+
+	// The previous input is the bypass signal, aligned with RNNoise's output
+	d.bypass = d.dry
+	vad, gain := d.denoise(frame)
+	if !d.fade.apply(frame, d.bypass[:], 1, d.fadeTarget(), d.fadeStep()) {
+		return FrameResult{GateGain: 1}
+	}
+	clipped := d.limit(frame)
+
+	return FrameResult{VoiceProbability: vad, Processed: true, GateGain: gain, ClippedSamples: clipped}
+}
+
+// denoise runs RNNoise on frame, blends in the delayed dry signal and applies
+// the gate, returning the voice probability and final gate gain
+func (d *Denoiser) denoise(frame []float32) (float32, float32) {
+	vad := d.engine.process(&d.wet, frame)
+
+	// Blend denoised and delay-aligned dry audio
+	strength := d.Strength()
+	for i, sample := range frame {
+		frame[i] = strength*d.wet[i] + (1-strength)*d.dry[i]
+		d.dry[i] = sample
+	}
+
+	// Attenuate frames that RNNoise doesn't consider speech
+	gain := d.gate.Apply(frame, vad)
+	return vad, gain
+}
+
+// limit counts samples beyond full scale, optionally rounding them off, and
+// returns how many clipped
+func (d *Denoiser) limit(frame []float32) int {
+	clipped := 0
+	soft := d.softClip.Load()
+	for i, sample := range frame {
+		if isClipping(sample) {
+			clipped++
+		}
+		if soft {
+			frame[i] = softClip(sample)
+		}
+	}
+	if clipped > 0 {
+		d.clips.Add(uint64(clipped))
+	}
+	return clipped
+}
+
+// SetStrength sets how much of the denoised signal is used, from 0 (original
+// audio only) to 1 (fully denoised)
+func (d *Denoiser) SetStrength(strength float32) {
+	strength = float32(math.Max(0, math.Min(1, float64(strength))))
+	d.strength.Store(math.Float32bits(strength))
+}
+
+// Strength returns the current suppression strength (0-1)
+func (d *Denoiser) Strength() float32 {
+	return math.Float32frombits(d.strength.Load())
+}
+
+// SetSoftClip enables smooth compression of peaks near full scale instead of
+// hard saturation
+func (d *Denoiser) SetSoftClip(enabled bool) {
+	d.softClip.Store(enabled)
+}
+
+// SoftClip reports whether soft clipping is enabled
+func (d *Denoiser) SoftClip() bool {
+	return d.softClip.Load()
+}
+
+// ClipCount returns the total number of output samples that exceeded full
+// scale since the Denoiser was created or ResetClipCount was called
+func (d *Denoiser) ClipCount() uint64 {
+	return d.clips.Load()
+}
+
+// ResetClipCount sets the clip counter back to zero
+func (d *Denoiser) ResetClipCount() {
+	d.clips.Store(0)
+}
+
+// ModelPath returns the path of the loaded model file (empty for the
+// built-in model)
+func (d *Denoiser) ModelPath() string {
+	return d.modelPath
+}
+
+// Latency returns the delay in samples of the output, which is the same
+// whether noise cancellation is enabled or bypassed
+func (d *Denoiser) Latency() int {
+	return rnnoiseDelay
+}
+
+// Gate returns the Denoiser's voice-activity noise gate
+func (d *Denoiser) Gate() *Gate {
+	return d.gate
+}
+
+// SetGateThreshold sets the voice probability below which audio is gated
+// (0 disables the gate)
+func (d *Denoiser) SetGateThreshold(threshold float32) {
+	d.gate.SetThreshold(threshold)
+}
 
-	// Apply RNNoise to the audio frame
-	C.rnnoise_process_frame(den, (*C.float)(unsafe.Pointer(&inFloat[0])), (*C.float)(unsafe.Pointer(&inFloat[0])))
+// Toggle switches noise cancellation on/off
+func (d *Denoiser) Toggle() bool {
+	newState := !d.enabled.Load()
+	d.enabled.Store(newState)
+	return newState
+}
 
-	// Convert float samples back to int16
-	for i := range inFloat {
-		inputAudio[i] = int16(inFloat[i])
+// Enable turns on noise cancellation
+func (d *Denoiser) Enable() {
+	d.enabled.Store(true)
+}
+
+// Disable turns off noise cancellation
+func (d *Denoiser) Disable() {
+	d.enabled.Store(false)
+}
+
+// IsEnabled returns the current state of noise cancellation
+func (d *Denoiser) IsEnabled() bool {
+	return d.enabled.Load()
+}
+
+// Reset clears RNNoise's recurrent state, the dry delay line and the gate so
+// audio from a previous stream doesn't leak into the next one. Must not be
+// called while Process is running.
+func (d *Denoiser) Reset() {
+	if d.engine != nil {
+		d.engine.reset()
 	}
+	d.dry = [rnnoiseDelay]float32{}
+	d.gate.Reset()
+	d.fade.mix = d.fadeTarget()
+}
+
+// Close destroys the RNNoise state; the Denoiser must not be used afterwards
+func (d *Denoiser) Close() {
+	if d.engine != nil {
+		d.engine.close()
+		d.engine = nil
+	}
+}
+
+// Execute denoises one frame in place using the default Denoiser
+func Execute(inputAudio []int16) {
+	defaultDenoiser.Process(inputAudio)
+}
+
+// ExecuteWithVAD denoises one frame in place using the default Denoiser and
+// returns the per-frame result, including the voice activity probability
+func ExecuteWithVAD(inputAudio []int16) FrameResult {
+	return defaultDenoiser.Process(inputAudio)
+}
+
+// ExecuteFloat32 denoises one normalised float32 frame in place using the
+// default Denoiser
+func ExecuteFloat32(frame []float32) FrameResult {
+	return defaultDenoiser.ProcessFloat32(frame)
 }
 
 // Toggle switches noise cancellation on/off
 func Toggle() bool {
-	newState := !enabled.Load()
-	enabled.Store(newState)
-	return newState
+	return defaultDenoiser.Toggle()
 }
 
 // Enable turns on noise cancellation
 func Enable() {
-	enabled.Store(true)
+	defaultDenoiser.Enable()
 }
 
 // Disable turns off noise cancellation
 func Disable() {
-	enabled.Store(false)
+	defaultDenoiser.Disable()
 }
 
 // IsEnabled returns the current state of noise cancellation
 func IsEnabled() bool {
-	return enabled.Load()
+	return defaultDenoiser.IsEnabled()
 }
 
-// Terminate destroys the RNNoise state (call only on final exit)
+// LoadModel loads a custom RNNoise model file into the default Denoiser and
+// the per-channel states set up by SetChannels (empty path restores the
+// built-in model)
+func LoadModel(path string) error {
+	return defaultMulti.LoadModel(path)
+}
+
+// SetStrength sets the suppression strength (0-1) of the default Denoiser
+func SetStrength(strength float32) {
+	defaultDenoiser.SetStrength(strength)
+}
+
+// Strength returns the suppression strength of the default Denoiser
+func Strength() float32 {
+	return defaultDenoiser.Strength()
+}
+
+// SetGateThreshold sets the noise gate threshold of the default Denoiser
+func SetGateThreshold(threshold float32) {
+	defaultDenoiser.SetGateThreshold(threshold)
+}
+
+// SetSoftClip enables or disables soft clipping on the default Denoiser
+func SetSoftClip(enabled bool) {
+	defaultDenoiser.SetSoftClip(enabled)
+}
+
+// ClipCount returns the clip counter of the default Denoiser
+func ClipCount() uint64 {
+	return defaultDenoiser.ClipCount()
+}
+
+// Terminate destroys the default RNNoise state (call only on final exit)
 func Terminate() {
-	if den != nil {
-		C.rnnoise_destroy(den)
-		den = nil
-	}
+	defaultMulti.Close()
+	defaultDenoiser.Close()
+}
+
+// Reset clears the state of the default Denoiser and every channel of the
+// layout set by SetChannels, e.g. when switching microphones. Must not be
+// called while audio is being processed.
+func Reset() {
+	defaultMulti.Reset()
 }
 
 // Close is deprecated, use Terminate() instead
 // Kept for backward compatibility with CLI version
 func Close() {
-	// Do nothing - RNNoise state should persist across start/stop cycles
-	// Only call Terminate() when the application exits completely
+	// Do nothing - the RNNoise state is reused across start/stop cycles; call
+	// Reset() to clear it and Terminate() when the application exits
 }
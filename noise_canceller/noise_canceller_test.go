@@ -1,6 +1,8 @@
 package noise_canceller
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -16,7 +18,7 @@ func TestConstants(t *testing.T) {
 func TestInitialState(t *testing.T) {
 	t.Run("InitiallyEnabled", func(t *testing.T) {
 		// Reset to initial state
-		enabled.Store(true)
+		defaultDenoiser.enabled.Store(true)
 
 		if !IsEnabled() {
 			t.Error("Expected noise cancellation to be enabled initially")
@@ -37,7 +39,7 @@ func TestToggle(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Set initial state
-			enabled.Store(tt.initialState)
+			defaultDenoiser.enabled.Store(tt.initialState)
 
 			// Toggle
 			newState := Toggle()
@@ -56,7 +58,7 @@ func TestToggle(t *testing.T) {
 func TestMultipleToggles(t *testing.T) {
 	t.Run("MultipleToggleCycles", func(t *testing.T) {
 		// Start with known state (enabled = true)
-		enabled.Store(true)
+		defaultDenoiser.enabled.Store(true)
 
 		states := []bool{}
 		for i := 0; i < 10; i++ {
@@ -86,7 +88,7 @@ func TestIsEnabled(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			enabled.Store(tt.state)
+			defaultDenoiser.enabled.Store(tt.state)
 
 			if IsEnabled() != tt.state {
 				t.Errorf("IsEnabled() = %v, want %v", IsEnabled(), tt.state)
@@ -96,27 +98,30 @@ func TestIsEnabled(t *testing.T) {
 }
 
 func TestExecuteWhenDisabled(t *testing.T) {
-	t.Run("ExecuteSkipsProcessingWhenDisabled", func(t *testing.T) {
+	t.Run("ExecuteBypassesWithConstantDelayWhenDisabled", func(t *testing.T) {
 		// Create test audio data
 		testAudio := make([]int16, frameSize)
 		for i := range testAudio {
 			testAudio[i] = int16(i * 100)
 		}
 
-		// Create a copy to compare
+		// Disable noise cancellation and let the crossfade finish
+		defaultDenoiser.enabled.Store(false)
+		defer defaultDenoiser.enabled.Store(true)
+		for i := 0; i < 3; i++ {
+			Execute(make([]int16, frameSize))
+		}
+
+		// The bypassed audio comes out one frame later, unchanged
 		originalAudio := make([]int16, frameSize)
 		copy(originalAudio, testAudio)
-
-		// Disable noise cancellation
-		enabled.Store(false)
-
-		// Execute should not modify the audio when disabled
 		Execute(testAudio)
+		next := make([]int16, frameSize)
+		Execute(next)
 
-		// Verify audio is unchanged
-		for i := range testAudio {
-			if testAudio[i] != originalAudio[i] {
-				t.Errorf("Audio modified at index %d: got %d, want %d", i, testAudio[i], originalAudio[i])
+		for i := range next {
+			if next[i] != originalAudio[i] {
+				t.Errorf("Audio modified at index %d: got %d, want %d", i, next[i], originalAudio[i])
 			}
 		}
 	})
@@ -136,7 +141,7 @@ func TestExecuteWhenEnabled(t *testing.T) {
 		copy(originalAudio, testAudio)
 
 		// Enable noise cancellation
-		enabled.Store(true)
+		defaultDenoiser.enabled.Store(true)
 
 		// Execute should process the audio when enabled
 		Execute(testAudio)
@@ -158,7 +163,7 @@ func TestExecuteWithZeroAudio(t *testing.T) {
 		testAudio := make([]int16, frameSize)
 
 		// Enable noise cancellation
-		enabled.Store(true)
+		defaultDenoiser.enabled.Store(true)
 
 		// This should not panic
 		Execute(testAudio)
@@ -183,7 +188,7 @@ func TestExecuteWithMaxAmplitude(t *testing.T) {
 		}
 
 		// Enable noise cancellation
-		enabled.Store(true)
+		defaultDenoiser.enabled.Store(true)
 
 		// This should not panic or overflow
 		Execute(testAudio)
@@ -198,7 +203,7 @@ func TestExecuteWithMaxAmplitude(t *testing.T) {
 func TestConcurrentToggle(t *testing.T) {
 	t.Run("ConcurrentTogglesAreSafe", func(t *testing.T) {
 		// Reset to known state
-		enabled.Store(true)
+		defaultDenoiser.enabled.Store(true)
 
 		// Run multiple toggles concurrently
 		done := make(chan bool)
@@ -224,7 +229,7 @@ func TestConcurrentToggle(t *testing.T) {
 
 func TestConcurrentReadWrite(t *testing.T) {
 	t.Run("ConcurrentReadWriteIsSafe", func(t *testing.T) {
-		enabled.Store(true)
+		defaultDenoiser.enabled.Store(true)
 
 		done := make(chan bool)
 
@@ -261,9 +266,271 @@ func TestConcurrentReadWrite(t *testing.T) {
 	})
 }
 
+func TestExecuteWithVAD(t *testing.T) {
+	t.Run("ReportsVoiceProbabilityWhenEnabled", func(t *testing.T) {
+		defaultDenoiser.enabled.Store(true)
+
+		testAudio := make([]int16, frameSize)
+		for i := range testAudio {
+			testAudio[i] = int16((i % 100) * 300)
+		}
+
+		result := ExecuteWithVAD(testAudio)
+		if !result.Processed {
+			t.Error("Expected frame to be processed when enabled")
+		}
+		if result.VoiceProbability < 0 || result.VoiceProbability > 1 {
+			t.Errorf("VoiceProbability = %v, want value in [0, 1]", result.VoiceProbability)
+		}
+	})
+
+	t.Run("ReportsUnprocessedWhenDisabled", func(t *testing.T) {
+		defaultDenoiser.enabled.Store(false)
+		defer defaultDenoiser.enabled.Store(true)
+
+		// Frames during the crossfade still contain denoised audio
+		var result FrameResult
+		for i := 0; i < 3; i++ {
+			result = ExecuteWithVAD(make([]int16, frameSize))
+		}
+		if result.Processed {
+			t.Error("Expected frame to be skipped when disabled")
+		}
+		if result.VoiceProbability != 0 {
+			t.Errorf("VoiceProbability = %v, want 0 when disabled", result.VoiceProbability)
+		}
+	})
+}
+
+func TestStrength(t *testing.T) {
+	t.Run("DefaultsToFullStrength", func(t *testing.T) {
+		d := New()
+		defer d.Close()
+
+		if d.Strength() != 1 {
+			t.Errorf("Strength() = %v, want 1", d.Strength())
+		}
+	})
+
+	t.Run("ClampsToRange", func(t *testing.T) {
+		d := New()
+		defer d.Close()
+
+		d.SetStrength(1.5)
+		if d.Strength() != 1 {
+			t.Errorf("Strength() = %v, want 1", d.Strength())
+		}
+		d.SetStrength(-0.5)
+		if d.Strength() != 0 {
+			t.Errorf("Strength() = %v, want 0", d.Strength())
+		}
+	})
+
+	t.Run("ZeroStrengthOutputsDelayAlignedDry", func(t *testing.T) {
+		d := New()
+		defer d.Close()
+		d.SetStrength(0)
+
+		first := make([]int16, frameSize)
+		for i := range first {
+			first[i] = int16((i % 100) * 300)
+		}
+		original := make([]int16, frameSize)
+		copy(original, first)
+
+		d.Process(first)
+		for i, s := range first {
+			if s != 0 {
+				t.Fatalf("first frame sample %d = %d, want 0 (delay line starts silent)", i, s)
+			}
+		}
+
+		// The second frame's output is the first frame's input
+		second := make([]int16, frameSize)
+		d.Process(second)
+		for i := range second {
+			if second[i] != original[i] {
+				t.Fatalf("second frame sample %d = %d, want %d", i, second[i], original[i])
+			}
+		}
+	})
+}
+
+func TestProcessFloat32(t *testing.T) {
+	t.Run("MatchesInt16Path", func(t *testing.T) {
+		intDenoiser := New()
+		floatDenoiser := New()
+		defer intDenoiser.Close()
+		defer floatDenoiser.Close()
+
+		for frame := 0; frame < 5; frame++ {
+			intFrame := make([]int16, frameSize)
+			floatFrame := make([]float32, frameSize)
+			for i := range intFrame {
+				intFrame[i] = int16(((i+frame*frameSize)%100)*300 - 15000)
+				floatFrame[i] = float32(intFrame[i]) / int16Scale
+			}
+
+			intDenoiser.Process(intFrame)
+			floatDenoiser.ProcessFloat32(floatFrame)
+
+			// The float path only differs by the final int16 quantisation
+			for i := range intFrame {
+				diff := float32(intFrame[i]) - floatFrame[i]*int16Scale
+				if diff < -1 || diff > 1 {
+					t.Fatalf("frame %d sample %d: int16 %d, float32 %v", frame, i, intFrame[i], floatFrame[i]*int16Scale)
+				}
+			}
+		}
+	})
+
+	t.Run("ExecuteFloat32UsesDefaultDenoiser", func(t *testing.T) {
+		defaultDenoiser.enabled.Store(true)
+
+		if result := ExecuteFloat32(make([]float32, frameSize)); !result.Processed {
+			t.Error("Expected frame to be processed when enabled")
+		}
+	})
+}
+
+func TestLoadModel(t *testing.T) {
+	t.Run("EmptyPathUsesBuiltinModel", func(t *testing.T) {
+		d, err := NewWithModel("")
+		if err != nil {
+			t.Fatalf("NewWithModel(\"\") returned error: %v", err)
+		}
+		defer d.Close()
+
+		if d.engine == nil || d.ModelPath() != "" {
+			t.Error("Expected built-in model state")
+		}
+	})
+
+	t.Run("MissingFileReturnsError", func(t *testing.T) {
+		d, err := NewWithModel(filepath.Join(t.TempDir(), "missing.rnnn"))
+		if err == nil {
+			d.Close()
+			t.Fatal("Expected error for missing model file")
+		}
+	})
+
+	t.Run("InvalidFileKeepsCurrentState", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "invalid.rnnn")
+		if err := os.WriteFile(path, []byte("not a model"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		d := New()
+		defer d.Close()
+		engine := d.engine
+
+		if err := d.LoadModel(path); err == nil {
+			t.Fatal("Expected error for invalid model file")
+		}
+		if d.engine != engine {
+			t.Error("Failed load replaced the existing RNNoise state")
+		}
+
+		// The denoiser must keep working after a failed load
+		if result := d.Process(make([]int16, frameSize)); !result.Processed {
+			t.Error("Expected frame to be processed after failed model load")
+		}
+	})
+}
+
+func TestDenoiserInstances(t *testing.T) {
+	t.Run("InstancesHaveIndependentState", func(t *testing.T) {
+		a := New()
+		b := New()
+		defer a.Close()
+		defer b.Close()
+
+		if a.engine == b.engine {
+			t.Fatal("Expected each Denoiser to own its RNNoise state")
+		}
+
+		a.Disable()
+		if !b.IsEnabled() {
+			t.Error("Disabling one Denoiser affected another")
+		}
+		if IsEnabled() != defaultDenoiser.IsEnabled() {
+			t.Error("Package functions should reflect the default Denoiser")
+		}
+	})
+
+	t.Run("ConcurrentInstancesAreSafe", func(t *testing.T) {
+		done := make(chan bool)
+		for i := 0; i < 4; i++ {
+			go func() {
+				d := New()
+				defer d.Close()
+
+				frame := make([]int16, frameSize)
+				for j := 0; j < 50; j++ {
+					for k := range frame {
+						frame[k] = int16((k % 50) * 200)
+					}
+					d.Process(frame)
+				}
+				done <- true
+			}()
+		}
+
+		for i := 0; i < 4; i++ {
+			<-done
+		}
+	})
+
+	t.Run("ResetMatchesNewInstance", func(t *testing.T) {
+		for _, mode := range []ChannelMode{Independent, Linked} {
+			used := New()
+			defer used.Close()
+			fresh := New()
+			defer fresh.Close()
+			usedMulti := newMulti(t, used, 2, mode)
+			defer usedMulti.Close()
+			freshMulti := newMulti(t, fresh, 2, mode)
+			defer freshMulti.Close()
+
+			input := stereoFrames(8)
+			step := frameSize * 2
+			for start := 0; start < 4*step; start += step {
+				usedMulti.ProcessFloat32(append([]float32(nil), input[start:start+step]...))
+			}
+			usedMulti.Reset()
+
+			// Audio from before the reset must not leak into what follows
+			for start := 4 * step; start < len(input); start += step {
+				got := append([]float32(nil), input[start:start+step]...)
+				want := append([]float32(nil), input[start:start+step]...)
+				usedMulti.ProcessFloat32(got)
+				freshMulti.ProcessFloat32(want)
+				for i := range want {
+					if got[i] != want[i] {
+						t.Fatalf("%s: sample %d after Reset = %v, want %v", mode, start+i, got[i], want[i])
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("ProcessAfterCloseIsNoop", func(t *testing.T) {
+		d := New()
+		d.Close()
+		d.Close() // Closing twice must be safe
+
+		frame := make([]int16, frameSize)
+		frame[0] = 1234
+		d.Process(frame)
+		if frame[0] != 1234 {
+			t.Errorf("Closed Denoiser modified audio: got %d, want 1234", frame[0])
+		}
+	})
+}
+
 // BenchmarkToggle benchmarks the toggle operation
 func BenchmarkToggle(b *testing.B) {
-	enabled.Store(true)
+	defaultDenoiser.enabled.Store(true)
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -273,7 +540,7 @@ func BenchmarkToggle(b *testing.B) {
 
 // BenchmarkIsEnabled benchmarks reading the enabled state
 func BenchmarkIsEnabled(b *testing.B) {
-	enabled.Store(true)
+	defaultDenoiser.enabled.Store(true)
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -288,7 +555,8 @@ func BenchmarkExecuteDisabled(b *testing.B) {
 		testAudio[i] = int16(i * 100)
 	}
 
-	enabled.Store(false)
+	defaultDenoiser.enabled.Store(false)
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -303,10 +571,95 @@ func BenchmarkExecuteEnabled(b *testing.B) {
 		testAudio[i] = int16(i * 100)
 	}
 
-	enabled.Store(true)
+	defaultDenoiser.enabled.Store(true)
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		Execute(testAudio)
 	}
 }
+
+// TestProcessingDoesNotAllocate guards the real-time path: processing a frame
+// must not allocate, or the audio thread ends up waiting on the GC
+func TestProcessingDoesNotAllocate(t *testing.T) {
+	d := New()
+	defer d.Close()
+	d.SetGateThreshold(0.5)
+	d.SetSoftClip(true)
+
+	int16Frame := make([]int16, frameSize)
+	floatFrame := make([]float32, frameSize)
+	stereoFrame := make([]float32, frameSize*2)
+	chunk := make([]float32, 256)
+
+	linked := newMulti(t, d, 2, Linked)
+	defer linked.Close()
+	independent := newMulti(t, d, 2, Independent)
+	defer independent.Close()
+	stream := NewStream(d)
+	stage := NewStage(newMulti(t, d, 1, Independent))
+
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Process", func() { d.Process(int16Frame) }},
+		{"ProcessFloat32", func() { d.ProcessFloat32(floatFrame) }},
+		{"Execute", func() { Execute(int16Frame) }},
+		{"ExecuteFloat32", func() { ExecuteFloat32(floatFrame) }},
+		{"Stream", func() { stream.ProcessFloat32(chunk) }},
+		{"Linked", func() { linked.ProcessFloat32(stereoFrame) }},
+		{"Independent", func() { independent.ProcessFloat32(stereoFrame) }},
+		{"Stage", func() { _ = stage.Process(floatFrame) }},
+		{"Toggling", func() { d.Toggle(); d.ProcessFloat32(floatFrame) }},
+		{"Reset", func() { d.Reset() }},
+		{"ResetLinked", func() { linked.Reset() }},
+		{"ResetIndependent", func() { independent.Reset() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allocs := testing.AllocsPerRun(100, tt.fn); allocs != 0 {
+				t.Errorf("%s allocates %.1f times per frame, want 0", tt.name, allocs)
+			}
+		})
+	}
+}
+
+// BenchmarkProcessFloat32 benchmarks denoising one float32 frame
+func BenchmarkProcessFloat32(b *testing.B) {
+	d := New()
+	defer d.Close()
+
+	frame := make([]float32, frameSize)
+	for i := range frame {
+		frame[i] = float32(i%100) / 100
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		d.ProcessFloat32(frame)
+	}
+}
+
+// BenchmarkMultiDenoiser benchmarks denoising one stereo frame in each mode
+func BenchmarkMultiDenoiser(b *testing.B) {
+	for _, mode := range []ChannelMode{Independent, Linked} {
+		b.Run(mode.String(), func(b *testing.B) {
+			d := New()
+			defer d.Close()
+			m := newMulti(b, d, 2, mode)
+			defer m.Close()
+
+			frame := make([]float32, frameSize*2)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				m.ProcessFloat32(frame)
+			}
+		})
+	}
+}
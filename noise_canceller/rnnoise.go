@@ -0,0 +1,101 @@
+//go:build cgo && !purego
+
+package noise_canceller
+
+/*
+#cgo LDFLAGS: -lrnnoise
+#include <stdio.h>
+#include <stdlib.h>
+#include <rnnoise.h>
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// EngineName identifies the noise suppression engine compiled in
+const EngineName = "RNNoise"
+
+// engine runs RNNoise on single frames
+type engine struct {
+	state *C.DenoiseState
+	model *C.RNNModel // nil when using the built-in model
+
+	// in and out are the RNNoise buffers, kept here so processing a frame
+	// doesn't allocate
+	in  [frameSize]C.float
+	out [frameSize]C.float
+}
+
+// newEngine creates an RNNoise state from the model file at path (empty path
+// uses the built-in model)
+func newEngine(path string) (*engine, error) {
+	var model *C.RNNModel
+	if path != "" {
+		var err error
+		if model, err = loadModelFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	state := C.rnnoise_create(model)
+	if state == nil {
+		if model != nil {
+			C.rnnoise_model_free(model)
+		}
+		return nil, fmt.Errorf("failed to create RNNoise state from model %s", path)
+	}
+	return &engine{state: state, model: model}, nil
+}
+
+// loadModelFile reads an RNNoise model, returning an error for files that
+// can't be opened or aren't valid models
+func loadModelFile(path string) (*C.RNNModel, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	cMode := C.CString("rb")
+	defer C.free(unsafe.Pointer(cMode))
+
+	f, err := C.fopen(cPath, cMode)
+	if f == nil {
+		return nil, fmt.Errorf("failed to open model %s: %v", path, err)
+	}
+	defer C.fclose(f)
+
+	model := C.rnnoise_model_from_file(f)
+	if model == nil {
+		return nil, fmt.Errorf("invalid RNNoise model file: %s", path)
+	}
+	return model, nil
+}
+
+// process denoises one normalised frame into out, delayed by rnnoiseDelay,
+// and returns the voice probability
+func (e *engine) process(out *[frameSize]float32, frame []float32) float32 {
+	for i, sample := range frame {
+		e.in[i] = C.float(sample * int16Scale)
+	}
+
+	// Apply RNNoise to the audio frame
+	vad := C.rnnoise_process_frame(e.state, &e.out[0], &e.in[0])
+
+	for i, sample := range e.out {
+		out[i] = float32(sample) / int16Scale
+	}
+	return float32(vad)
+}
+
+// reset clears RNNoise's recurrent state
+func (e *engine) reset() {
+	C.rnnoise_init(e.state, e.model)
+}
+
+// close destroys the RNNoise state
+func (e *engine) close() {
+	C.rnnoise_destroy(e.state)
+	// The model must outlive the state created from it
+	if e.model != nil {
+		C.rnnoise_model_free(e.model)
+	}
+}
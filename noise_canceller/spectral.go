@@ -0,0 +1,193 @@
+//go:build !cgo || purego
+
+package noise_canceller
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/errakhaoui/noise-canceling/dsp"
+)
+
+// EngineName identifies the noise suppression engine compiled in
+const EngineName = "Spectral (pure Go)"
+
+const (
+	// windowSize spans two frames so consecutive windows overlap by half,
+	// giving the same one-frame delay as RNNoise
+	windowSize = 2 * frameSize
+	fftSize    = 1024 // next power of two, the window is zero-padded
+	bins       = fftSize/2 + 1
+
+	// powerSmoothing is the recursive smoothing factor for the power
+	// spectrum that minimum statistics tracks
+	powerSmoothing = 0.85
+	// subwindowFrames and subwindows set the minimum-statistics search window
+	// (30 frames x 5 = 1.5s): long enough to span pauses between words
+	subwindowFrames = 30
+	subwindows      = 5
+	// minimumBias compensates for the minimum of a noisy power estimate
+	// sitting below the true noise mean
+	minimumBias = 1.5
+
+	// priorSmoothing weights the previous frame in the decision-directed
+	// a priori SNR estimate, which suppresses musical noise
+	priorSmoothing = 0.98
+	// gainFloor limits attenuation to -20 dB so residual noise stays natural
+	gainFloor = 0.1
+
+	// Voice probability is derived from the a priori SNR in the speech band
+	voiceLowHz  = 300
+	voiceHighHz = 4000
+)
+
+// engine suppresses noise with an STFT Wiener filter whose noise spectrum is
+// tracked by minimum statistics, so it adapts without needing a noise-only
+// calibration period
+type engine struct {
+	fft      *dsp.FFT
+	window   [windowSize]float32 // square-root Hann, used for analysis and synthesis
+	input    [windowSize]float32 // previous and current frame
+	overlap  [frameSize]float32  // second half of the previous synthesis
+	spectrum [fftSize]complex128
+
+	power      [bins]float64 // smoothed power spectrum
+	noise      [bins]float64 // estimated noise power
+	currentMin [bins]float64 // minimum within the current subwindow
+	minimums   [subwindows][bins]float64
+	prevGain   [bins]float64
+	prevSNR    [bins]float64 // previous a posteriori SNR
+	frames     int
+}
+
+// newEngine creates a spectral suppressor. Custom RNNoise models can't be
+// used without cgo, so any non-empty path is an error.
+func newEngine(path string) (*engine, error) {
+	if path != "" {
+		return nil, fmt.Errorf("custom models require the RNNoise engine (this build uses %s): %s", EngineName, path)
+	}
+
+	e := &engine{fft: dsp.NewFFT(fftSize)}
+	for i := range e.window {
+		e.window[i] = float32(math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/windowSize)))
+	}
+	e.reset()
+	return e, nil
+}
+
+// process denoises one normalised frame into out, delayed by rnnoiseDelay,
+// and returns the voice probability
+func (e *engine) process(out *[frameSize]float32, frame []float32) float32 {
+	copy(e.input[:frameSize], e.input[frameSize:])
+	copy(e.input[frameSize:], frame)
+
+	for i, sample := range e.input {
+		e.spectrum[i] = complex(float64(sample*e.window[i]), 0)
+	}
+	for i := windowSize; i < fftSize; i++ {
+		e.spectrum[i] = 0
+	}
+	e.fft.Forward(e.spectrum[:])
+
+	e.updateNoise()
+	vad := e.applyGains()
+
+	e.fft.Inverse(e.spectrum[:])
+
+	// Overlap-add: the squared window sums to one at 50% overlap
+	for i := range out {
+		out[i] = e.overlap[i] + float32(real(e.spectrum[i]))*e.window[i]
+		e.overlap[i] = float32(real(e.spectrum[i+frameSize])) * e.window[i+frameSize]
+	}
+	return vad
+}
+
+// updateNoise tracks the minimum of the smoothed power spectrum over the
+// last subwindows*subwindowFrames frames
+func (e *engine) updateNoise() {
+	first := e.frames == 0
+	for k := range e.power {
+		p := periodogram(e.spectrum[k])
+		if first {
+			e.power[k] = p
+		} else {
+			e.power[k] = powerSmoothing*e.power[k] + (1-powerSmoothing)*p
+		}
+		e.currentMin[k] = math.Min(e.currentMin[k], e.power[k])
+	}
+
+	e.frames++
+	if e.frames%subwindowFrames == 0 {
+		slot := (e.frames / subwindowFrames) % subwindows
+		e.minimums[slot] = e.currentMin
+		for k := range e.currentMin {
+			e.currentMin[k] = math.Inf(1)
+		}
+	}
+
+	for k := range e.noise {
+		minimum := e.currentMin[k]
+		for _, sub := range e.minimums {
+			minimum = math.Min(minimum, sub[k])
+		}
+		if math.IsInf(minimum, 1) {
+			minimum = e.power[k]
+		}
+		e.noise[k] = math.Max(minimumBias*minimum, 1e-12)
+	}
+}
+
+// applyGains scales the spectrum by decision-directed Wiener gains and
+// maps the mean a priori SNR over the speech band to a voice probability
+func (e *engine) applyGains() float32 {
+	binHz := float64(sampleRate) / fftSize
+	var voice float64
+	voiceBins := 0
+
+	for k := range e.noise {
+		snr := periodogram(e.spectrum[k]) / e.noise[k]
+		prior := priorSmoothing*e.prevGain[k]*e.prevGain[k]*e.prevSNR[k] +
+			(1-priorSmoothing)*math.Max(snr-1, 0)
+		wiener := prior / (1 + prior)
+		gain := math.Max(wiener, gainFloor)
+
+		e.prevGain[k] = gain
+		e.prevSNR[k] = snr
+
+		e.spectrum[k] *= complex(gain, 0)
+		if k > 0 && k < fftSize/2 {
+			e.spectrum[fftSize-k] *= complex(gain, 0)
+		}
+
+		if freq := float64(k) * binHz; freq >= voiceLowHz && freq <= voiceHighHz {
+			voice += prior
+			voiceBins++
+		}
+	}
+	voice /= float64(voiceBins)
+	return float32(voice / (1 + voice))
+}
+
+// periodogram returns the power of one spectral bin
+func periodogram(bin complex128) float64 {
+	return real(bin)*real(bin) + imag(bin)*imag(bin)
+}
+
+// reset forgets all audio and the noise estimate
+func (e *engine) reset() {
+	e.input = [windowSize]float32{}
+	e.overlap = [frameSize]float32{}
+	e.power = [bins]float64{}
+	e.prevGain = [bins]float64{}
+	e.prevSNR = [bins]float64{}
+	for k := range e.currentMin {
+		e.currentMin[k] = math.Inf(1)
+	}
+	for i := range e.minimums {
+		e.minimums[i] = e.currentMin
+	}
+	e.frames = 0
+}
+
+// close releases nothing; the engine is garbage collected
+func (e *engine) close() {}
@@ -0,0 +1,120 @@
+//go:build !cgo || purego
+
+package noise_canceller
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// burstSignal returns white noise with 1 kHz tone bursts standing in for
+// speech: the tone is on for the first 20 of every 100 frames
+func burstSignal(frames int) (noisy, tone []float32) {
+	rng := rand.New(rand.NewSource(1))
+	noisy = make([]float32, frames*frameSize)
+	tone = make([]float32, len(noisy))
+	for i := range noisy {
+		if (i/frameSize)%100 < 20 {
+			tone[i] = float32(0.3 * math.Sin(2*math.Pi*1000*float64(i)/sampleRate))
+		}
+		noisy[i] = tone[i] + float32(rng.NormFloat64()*0.05)
+	}
+	return noisy, tone
+}
+
+func TestSpectralSuppressesNoise(t *testing.T) {
+	d := New()
+	defer d.Close()
+
+	const frames = 500
+	noisy, tone := burstSignal(frames)
+	output := make([]float32, len(noisy))
+	copy(output, noisy)
+
+	vads := make([]float32, frames)
+	for f := 0; f < frames; f++ {
+		vads[f] = d.ProcessFloat32(output[f*frameSize : (f+1)*frameSize]).VoiceProbability
+	}
+
+	// Measure after the noise estimate has settled. Output frame f holds
+	// input frame f-1.
+	var noiseIn, noiseOut, toneIn, toneErr float64
+	var vadSpeech, vadNoise float64
+	speechFrames, noiseFrames := 0, 0
+	for f := 200; f < frames; f++ {
+		in := f - 1
+		speech := in%100 < 20
+		// Skip frames next to burst edges, where the window straddles both
+		edge := in%100 == 0 || in%100 == 19 || in%100 == 20 || in%100 == 99
+		if edge {
+			continue
+		}
+
+		for i := 0; i < frameSize; i++ {
+			x := float64(noisy[in*frameSize+i])
+			y := float64(output[f*frameSize+i])
+			s := float64(tone[in*frameSize+i])
+			if speech {
+				toneIn += s * s
+				toneErr += (y - s) * (y - s)
+			} else {
+				noiseIn += x * x
+				noiseOut += y * y
+			}
+		}
+		if speech {
+			vadSpeech += float64(vads[in])
+			speechFrames++
+		} else {
+			vadNoise += float64(vads[in])
+			noiseFrames++
+		}
+	}
+
+	reduction := 10 * math.Log10(noiseIn/noiseOut)
+	if reduction < 10 {
+		t.Errorf("noise reduced by %.1f dB, want at least 10 dB", reduction)
+	}
+
+	// The tone must come through with a better SNR than the noisy input had
+	inputSNR := 10 * math.Log10(toneIn/(0.05*0.05*frameSize*float64(speechFrames)))
+	outputSNR := 10 * math.Log10(toneIn/toneErr)
+	if outputSNR < inputSNR+3 {
+		t.Errorf("tone SNR %.1f dB after processing, want at least %.1f dB", outputSNR, inputSNR+3)
+	}
+
+	speechVAD := vadSpeech / float64(speechFrames)
+	noiseVAD := vadNoise / float64(noiseFrames)
+	if speechVAD < 0.5 || noiseVAD > 0.2 {
+		t.Errorf("voice probability %.2f during tone, %.2f during noise: want above 0.5 and below 0.2",
+			speechVAD, noiseVAD)
+	}
+}
+
+func TestSpectralTransparentToSilence(t *testing.T) {
+	d := New()
+	defer d.Close()
+
+	frame := make([]float32, frameSize)
+	for f := 0; f < 10; f++ {
+		d.ProcessFloat32(frame)
+		for i, sample := range frame {
+			if sample != 0 {
+				t.Fatalf("frame %d sample %d = %v, want silence", f, i, sample)
+			}
+		}
+	}
+}
+
+func TestSpectralRejectsCustomModel(t *testing.T) {
+	d := New()
+	defer d.Close()
+
+	if err := d.LoadModel("voice.rnnn"); err == nil {
+		t.Error("LoadModel with a custom model succeeded without RNNoise, want error")
+	}
+	if d.ModelPath() != "" {
+		t.Errorf("ModelPath() = %q after failed load, want built-in", d.ModelPath())
+	}
+}
@@ -0,0 +1,76 @@
+package noise_canceller
+
+import (
+	"fmt"
+
+	"github.com/errakhaoui/noise-canceling/dsp"
+)
+
+// Stage wraps RNNoise as a dsp.Processor so it can run in a dsp.Chain
+// alongside other filters
+type Stage struct {
+	multi *MultiDenoiser // nil uses the default Denoiser's channel layout
+	last  FrameResult
+}
+
+var (
+	_ dsp.Processor   = (*Stage)(nil)
+	_ dsp.VoiceSource = (*Stage)(nil)
+)
+
+// NewStage creates a pipeline stage that denoises with m
+func NewStage(m *MultiDenoiser) *Stage {
+	return &Stage{multi: m}
+}
+
+// DefaultStage creates a pipeline stage backed by the default Denoiser,
+// following the channel layout set by SetChannels
+func DefaultStage() *Stage {
+	return &Stage{}
+}
+
+// denoiser returns the MultiDenoiser frames are passed to
+func (s *Stage) denoiser() *MultiDenoiser {
+	if s.multi != nil {
+		return s.multi
+	}
+	return defaultMulti
+}
+
+// Process denoises one interleaved frame of FrameSize samples per channel
+func (s *Stage) Process(frame []float32) error {
+	m := s.denoiser()
+	if len(frame) != frameSize*m.Channels() {
+		return fmt.Errorf("frame has %d samples, want %d", len(frame), frameSize*m.Channels())
+	}
+	s.last = m.ProcessFloat32(frame)
+	return nil
+}
+
+// Reset clears the RNNoise state of every channel
+func (s *Stage) Reset() {
+	s.denoiser().Reset()
+	s.last = FrameResult{}
+}
+
+// Latency returns RNNoise's algorithmic delay in samples
+func (s *Stage) Latency() int {
+	return s.denoiser().primary.Latency()
+}
+
+// String names the stage in latency reports
+func (s *Stage) String() string {
+	return "RNNoise"
+}
+
+// LastResult returns the result of the most recently processed frame,
+// including its voice probability
+func (s *Stage) LastResult() FrameResult {
+	return s.last
+}
+
+// VoiceProbability returns the voice probability of the most recent frame,
+// with ok false if that frame bypassed the denoiser
+func (s *Stage) VoiceProbability() (float32, bool) {
+	return s.last.VoiceProbability, s.last.Processed
+}
@@ -0,0 +1,63 @@
+package noise_canceller
+
+import (
+	"testing"
+
+	"github.com/errakhaoui/noise-canceling/dsp"
+)
+
+func TestStageInChain(t *testing.T) {
+	d := New()
+	defer d.Close()
+	d.SetStrength(0) // Dry output, so the delay is easy to check
+	m := newMulti(t, d, 1, Independent)
+	stage := NewStage(m)
+
+	chain := dsp.NewChain(stage)
+	if got := chain.Latency(); got != rnnoiseDelay {
+		t.Errorf("Latency() = %d, want %d", got, rnnoiseDelay)
+	}
+
+	frame := constantFrame(0.25)
+	if err := chain.Process(frame); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if !stage.LastResult().Processed {
+		t.Error("LastResult().Processed = false, want true")
+	}
+
+	frame = constantFrame(0)
+	_ = chain.Process(frame)
+	if frame[0] != 0.25 {
+		t.Errorf("delayed sample = %v, want 0.25", frame[0])
+	}
+}
+
+func TestStageRejectsWrongFrameSize(t *testing.T) {
+	d := New()
+	defer d.Close()
+	stage := NewStage(newMulti(t, d, 2, Linked))
+
+	if err := stage.Process(make([]float32, frameSize)); err == nil {
+		t.Error("Process() of a mono frame on a stereo stage succeeded, want error")
+	}
+}
+
+func TestStageReset(t *testing.T) {
+	d := New()
+	defer d.Close()
+	d.SetStrength(0)
+	stage := NewStage(newMulti(t, d, 1, Independent))
+
+	_ = stage.Process(constantFrame(0.5))
+	stage.Reset()
+
+	// Audio from before the reset must not come out afterwards
+	frame := constantFrame(0)
+	_ = stage.Process(frame)
+	for i, sample := range frame {
+		if sample != 0 {
+			t.Fatalf("sample %d = %v after Reset, want 0", i, sample)
+		}
+	}
+}
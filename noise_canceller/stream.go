@@ -0,0 +1,73 @@
+package noise_canceller
+
+// Stream adapts a Denoiser to buffers of any length by accumulating input
+// into RNNoise-sized frames. Output is delayed by a fixed Latency() samples.
+type Stream struct {
+	denoiser *Denoiser
+
+	pending [frameSize]float32 // input collected for the next frame
+	ready   [frameSize]float32 // last processed frame, drained as input arrives
+	pos     int                // position within pending and ready
+	last    FrameResult
+}
+
+// NewStream creates a streaming wrapper around d
+func NewStream(d *Denoiser) *Stream {
+	return &Stream{denoiser: d}
+}
+
+// Process denoises int16 audio of any length in place and returns how many
+// full frames were passed to the Denoiser
+func (s *Stream) Process(audio []int16) int {
+	frames := 0
+	for i, sample := range audio {
+		out, processed := s.push(float32(sample) / int16Scale)
+		audio[i] = toInt16(out)
+		if processed {
+			frames++
+		}
+	}
+	return frames
+}
+
+// ProcessFloat32 denoises normalised float32 audio of any length in place and
+// returns how many full frames were passed to the Denoiser
+func (s *Stream) ProcessFloat32(audio []float32) int {
+	frames := 0
+	for i, sample := range audio {
+		out, processed := s.push(sample)
+		audio[i] = out
+		if processed {
+			frames++
+		}
+	}
+	return frames
+}
+
+// push queues one input sample and returns the delayed output sample, along
+// with whether a full frame was just processed
+func (s *Stream) push(sample float32) (float32, bool) {
+	out := s.ready[s.pos]
+	s.pending[s.pos] = sample
+	s.pos++
+
+	if s.pos < frameSize {
+		return out, false
+	}
+
+	s.ready = s.pending
+	s.last = s.denoiser.ProcessFloat32(s.ready[:])
+	s.pos = 0
+	return out, true
+}
+
+// LastResult returns the result of the most recently processed frame
+func (s *Stream) LastResult() FrameResult {
+	return s.last
+}
+
+// Latency returns the delay in samples between input and output: one frame
+// of buffering plus the Denoiser's own algorithmic delay
+func (s *Stream) Latency() int {
+	return frameSize + s.denoiser.Latency()
+}
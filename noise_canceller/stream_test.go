@@ -0,0 +1,82 @@
+package noise_canceller
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStreamArbitraryBufferSizes(t *testing.T) {
+	for _, chunk := range []int{1, 100, 256, 480, 1024} {
+		t.Run(fmt.Sprintf("Chunk%d", chunk), func(t *testing.T) {
+			d := New()
+			defer d.Close()
+			// With zero strength the output is the dry signal, so the stream
+			// must reproduce the input delayed by exactly Latency() samples
+			d.SetStrength(0)
+			stream := NewStream(d)
+
+			total := frameSize * 8
+			input := make([]int16, total)
+			for i := range input {
+				input[i] = int16((i*37)%2000 - 1000)
+			}
+
+			output := make([]int16, 0, total)
+			frames := 0
+			for start := 0; start < total; start += chunk {
+				end := min(start+chunk, total)
+				buf := make([]int16, end-start)
+				copy(buf, input[start:end])
+				frames += stream.Process(buf)
+				output = append(output, buf...)
+			}
+
+			if frames != total/frameSize {
+				t.Errorf("chunk %d: processed %d frames, want %d", chunk, frames, total/frameSize)
+			}
+
+			latency := stream.Latency()
+			for i := range output {
+				want := int16(0)
+				if i >= latency {
+					want = input[i-latency]
+				}
+				if output[i] != want {
+					t.Fatalf("chunk %d: sample %d = %d, want %d", chunk, i, output[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamLatency(t *testing.T) {
+	d := New()
+	defer d.Close()
+
+	stream := NewStream(d)
+	if got, want := stream.Latency(), frameSize+d.Latency(); got != want {
+		t.Errorf("Latency() = %d, want %d", got, want)
+	}
+}
+
+func TestProcessRejectsWrongFrameSize(t *testing.T) {
+	d := New()
+	defer d.Close()
+
+	for _, size := range []int{frameSize / 2, frameSize * 2} {
+		frame := make([]int16, size)
+		for i := range frame {
+			frame[i] = 1000
+		}
+
+		// Must not panic, and must leave the buffer untouched
+		if result := d.Process(frame); result.Processed {
+			t.Errorf("size %d: expected frame to be rejected", size)
+		}
+		for i, s := range frame {
+			if s != 1000 {
+				t.Fatalf("size %d: sample %d modified to %d", size, i, s)
+			}
+		}
+	}
+}
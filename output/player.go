@@ -4,18 +4,40 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
+	"github.com/errakhaoui/noise-canceling/dsp"
 	"github.com/gordonklaus/portaudio"
 )
 
 const (
-	sampleRate   = 48000
-	frameSize    = 480
-	channelCount = 1
+	sampleRate = 48000
+	frameSize  = 480
+	int16Scale = 32768 // converts int16 samples to normalised float32
 )
 
-var outputStreams []*portaudio.Stream
-var outputBuffers [][]int16
+// outputStream is an open output device and the buffer written to it
+type outputStream struct {
+	stream   *portaudio.Stream
+	buffer   []float32
+	channels int // channels opened on the device, at most channelCount
+
+	// frame holds the audio downmixed to the device's channel count
+	frame []float32
+
+	// Resampling state, only used when the device can't play at sampleRate
+	resampler   *dsp.InterleavedResampler
+	resampleOut []float32
+	pending     []float32 // resampled audio waiting to fill buffer
+}
+
+var outputStreams []*outputStream
+
+// channelCount is the number of interleaved channels passed to ReadStream
+var channelCount = 1
+
+// int16Frame holds int16 audio converted to float32 by ReadStream
+var int16Frame = make([]float32, frameSize)
 
 func init() {
 	if err := portaudio.Initialize(); err != nil {
@@ -84,8 +106,22 @@ func StartOutputStreamToDevice(device *portaudio.DeviceInfo) error {
 	return StartOutputStreamToDevices(devices)
 }
 
-// StartOutputStreamToDevices initializes output streams to multiple devices
+// StartOutputStreamToDevices initializes mono output streams to multiple
+// devices
 func StartOutputStreamToDevices(devices []*portaudio.DeviceInfo) error {
+	return StartOutputStreamToDevicesWithChannels(devices, 1)
+}
+
+// StartOutputStreamToDevicesWithChannels initializes output streams for audio
+// with the given number of interleaved channels. Devices with fewer output
+// channels receive a downmix.
+func StartOutputStreamToDevicesWithChannels(devices []*portaudio.DeviceInfo, channels int) error {
+	if channels < 1 {
+		channels = 1
+	}
+	channelCount = channels
+	int16Frame = make([]float32, frameSize*channels)
+
 	// If no devices specified, use default
 	if len(devices) == 0 {
 		defaultDevice, err := portaudio.DefaultOutputDevice()
@@ -97,104 +133,199 @@ func StartOutputStreamToDevices(devices []*portaudio.DeviceInfo) error {
 
 	// Create a stream for each device
 	for _, device := range devices {
-		buffer := make([]int16, frameSize)
-
-		var streamParams portaudio.StreamParameters
-		streamParams.Output.Channels = channelCount
-		streamParams.SampleRate = sampleRate
-		streamParams.FramesPerBuffer = frameSize
-		streamParams.Output.Device = device
-
-		// Use higher latency for virtual audio devices to prevent underflow
-		// Virtual audio devices (BlackHole, Loopback, etc.) benefit from higher latency
-		if device.DefaultHighOutputLatency > 0 {
-			streamParams.Output.Latency = device.DefaultHighOutputLatency
-		} else {
-			streamParams.Output.Latency = device.DefaultLowOutputLatency
-		}
-
-		stream, err := portaudio.OpenStream(streamParams, buffer)
+		deviceChannels := max(1, min(channels, device.MaxOutputChannels))
+		out, err := openOutputStream(device, sampleRate, deviceChannels)
 		if err != nil {
-			// Clean up already opened streams
-			closeAllStreams()
-			return fmt.Errorf("error opening output stream to %s: %v", device.Name, err)
+			rate := int(device.DefaultSampleRate)
+			if rate <= 0 || rate == sampleRate {
+				closeAllStreams()
+				return fmt.Errorf("error opening output stream to %s: %v", device.Name, err)
+			}
+
+			log.Printf("Output device %s can't open at %d Hz (%v), playing at %d Hz with resampling",
+				device.Name, sampleRate, err, rate)
+
+			out, err = openOutputStream(device, rate, deviceChannels)
+			if err != nil {
+				closeAllStreams()
+				return fmt.Errorf("error opening output stream to %s at %d Hz: %v", device.Name, rate, err)
+			}
+			out.resampler = dsp.NewInterleavedResampler(sampleRate, rate, deviceChannels, frameSize)
+			out.resampleOut = make([]float32, out.resampler.MaxOutput(len(out.frame)))
+			out.pending = make([]float32, 0, len(out.buffer)+len(out.resampleOut))
 		}
 
-		err = stream.Start()
+		err = out.stream.Start()
 		if err != nil {
-			_ = stream.Close() // Ignore error on cleanup
+			_ = out.stream.Close() // Ignore error on cleanup
 			closeAllStreams()
 			return fmt.Errorf("error starting output stream to %s: %v", device.Name, err)
 		}
 
-		outputStreams = append(outputStreams, stream)
-		outputBuffers = append(outputBuffers, buffer)
-		log.Printf("Opened output stream to device: %s (latency: %.2fms)",
-			device.Name, streamParams.Output.Latency.Seconds()*1000)
+		outputStreams = append(outputStreams, out)
 	}
 
 	return nil
 }
 
+// openOutputStream opens a blocking float32 output stream to device at the
+// given rate, written in 10ms blocks
+func openOutputStream(device *portaudio.DeviceInfo, rate, channels int) (*outputStream, error) {
+	buffer := make([]float32, rate/100*channels)
+
+	var streamParams portaudio.StreamParameters
+	streamParams.Output.Channels = channels
+	streamParams.SampleRate = float64(rate)
+	streamParams.FramesPerBuffer = rate / 100
+	streamParams.Output.Device = device
+
+	// Use higher latency for virtual audio devices to prevent underflow
+	// Virtual audio devices (BlackHole, Loopback, etc.) benefit from higher latency
+	if device.DefaultHighOutputLatency > 0 {
+		streamParams.Output.Latency = device.DefaultHighOutputLatency
+	} else {
+		streamParams.Output.Latency = device.DefaultLowOutputLatency
+	}
+
+	stream, err := portaudio.OpenStream(streamParams, buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Opened output stream to device: %s (%d Hz, %d channels, latency: %.2fms)",
+		device.Name, rate, channels, streamParams.Output.Latency.Seconds()*1000)
+	return &outputStream{stream: stream, buffer: buffer, channels: channels, frame: make([]float32, frameSize*channels)}, nil
+}
+
+// Latency returns the playback delay of the first output device, the one the
+// audio is meant for, as reported by PortAudio and including its resampler.
+// It returns 0 while no stream is open.
+func Latency() time.Duration {
+	if len(outputStreams) == 0 {
+		return 0
+	}
+	out := outputStreams[0]
+	info := out.stream.Info()
+	if info == nil {
+		return 0
+	}
+
+	latency := info.OutputLatency
+	if out.resampler != nil && info.SampleRate > 0 {
+		latency += time.Duration(float64(out.resampler.Latency()) / info.SampleRate * float64(time.Second))
+	}
+	return latency
+}
+
 // closeAllStreams is a helper to close all streams (used internally)
 func closeAllStreams() {
-	for _, stream := range outputStreams {
-		if stream != nil {
-			_ = stream.Stop()  // Ignore error on cleanup
-			_ = stream.Close() // Ignore error on cleanup
+	for _, out := range outputStreams {
+		if out != nil {
+			_ = out.stream.Stop()  // Ignore error on cleanup
+			_ = out.stream.Close() // Ignore error on cleanup
 		}
 	}
 	outputStreams = nil
-	outputBuffers = nil
 }
 
-// ReadStream writes audio data to all output streams
+// ReadStream writes int16 audio data to all output streams
 func ReadStream(audioStream []int16) {
 	// Validate input size
-	if len(audioStream) != frameSize {
-		log.Printf("Warning: audio stream size mismatch: expected %d, got %d", frameSize, len(audioStream))
+	if len(audioStream) != frameSize*channelCount {
+		log.Printf("Warning: audio stream size mismatch: expected %d, got %d", frameSize*channelCount, len(audioStream))
+		return
+	}
+
+	for i, sample := range audioStream {
+		int16Frame[i] = float32(sample) / int16Scale
+	}
+	ReadStreamFloat32(int16Frame)
+}
+
+// ReadStreamFloat32 writes normalised (-1 to 1) float32 audio data to all
+// output streams. Multi-channel audio is interleaved.
+func ReadStreamFloat32(audioStream []float32) {
+	// Validate input size
+	if len(audioStream) != frameSize*channelCount {
+		log.Printf("Warning: audio stream size mismatch: expected %d, got %d", frameSize*channelCount, len(audioStream))
 		return
 	}
 
 	// Write to all output streams
-	for i, stream := range outputStreams {
-		// Ensure buffer is the correct size
-		if len(outputBuffers[i]) != frameSize {
-			log.Printf("Warning: output buffer %d size mismatch", i)
+	for i, out := range outputStreams {
+		frame := out.downmix(audioStream)
+
+		if out.resampler == nil {
+			// Copy audio data to this stream's buffer
+			copy(out.buffer, frame)
+			out.write(i)
 			continue
 		}
 
-		// Copy audio data to this stream's buffer
-		copy(outputBuffers[i], audioStream)
+		// Resample, then write every complete device block
+		n := out.resampler.Process(frame, out.resampleOut)
+		out.pending = append(out.pending, out.resampleOut[:n]...)
 
-		// Write to this output stream
-		err := stream.Write()
-		if err != nil {
-			// Underflow errors are common with virtual audio devices and can be ignored
-			// They happen when the output can't keep up with the input rate
-			errStr := err.Error()
-			if errStr != "Output underflowed" {
-				log.Printf("Error writing to output stream %d: %v", i, err)
-			}
-			// Otherwise silently ignore underflow errors
+		for len(out.pending) >= len(out.buffer) {
+			copy(out.buffer, out.pending)
+			out.pending = out.pending[:copy(out.pending, out.pending[len(out.buffer):])]
+			out.write(i)
+		}
+	}
+}
+
+// downmix returns audio with the stream's channel count: extra channels are
+// dropped, or averaged when the device is mono
+func (out *outputStream) downmix(audio []float32) []float32 {
+	if out.channels == channelCount {
+		return audio
+	}
+
+	scale := 1 / float32(channelCount)
+	for i := range out.frame {
+		frame, c := i/out.channels, i%out.channels
+		if out.channels > 1 {
+			out.frame[i] = audio[frame*channelCount+c]
+			continue
+		}
+
+		var sum float32
+		for _, sample := range audio[frame*channelCount : (frame+1)*channelCount] {
+			sum += sample
+		}
+		out.frame[i] = sum * scale
+	}
+	return out.frame
+}
+
+// write sends the stream's buffer to its device
+func (out *outputStream) write(index int) {
+	err := out.stream.Write()
+	if err != nil {
+		// Underflow errors are common with virtual audio devices and can be ignored
+		// They happen when the output can't keep up with the input rate.
+		// Compare the error code rather than its text, which would allocate
+		// on every underflow.
+		if err != portaudio.OutputUnderflowed {
+			log.Printf("Error writing to output stream %d: %v", index, err)
 		}
+		// Otherwise silently ignore underflow errors
 	}
 }
 
 // Close closes all output streams
 func Close() {
-	for i, stream := range outputStreams {
-		if stream != nil {
-			if err := stream.Stop(); err != nil {
+	for i, out := range outputStreams {
+		if out != nil {
+			if err := out.stream.Stop(); err != nil {
 				log.Printf("Error stopping output stream %d: %v", i, err)
 			}
-			if err := stream.Close(); err != nil {
+			if err := out.stream.Close(); err != nil {
 				log.Printf("Error closing output stream %d: %v", i, err)
 			}
 		}
 	}
 	outputStreams = nil
-	outputBuffers = nil
 }
 
 // Terminate closes PortAudio completely (call only on final exit)
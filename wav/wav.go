@@ -0,0 +1,187 @@
+// Package wav reads and writes WAV files as normalised float32 audio, for
+// test fixtures and offline processing
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Audio is decoded WAV audio
+type Audio struct {
+	SampleRate int
+	Channels   int
+	// Samples are interleaved and normalised to -1 to 1
+	Samples []float32
+}
+
+const (
+	formatPCM   = 1
+	formatFloat = 3
+)
+
+// Read decodes a 16-bit PCM or 32-bit float WAV file
+func Read(path string) (*Audio, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	audio, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return audio, nil
+}
+
+// Decode reads a 16-bit PCM or 32-bit float WAV stream
+func Decode(r io.Reader) (*Audio, error) {
+	var header struct {
+		RIFF [4]byte
+		Size uint32
+		WAVE [4]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	if string(header.RIFF[:]) != "RIFF" || string(header.WAVE[:]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+
+	var format struct {
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}
+	haveFormat := false
+
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return nil, errors.New("no data chunk")
+		}
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			body := make([]byte, chunk.Size+chunk.Size%2)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("reading format: %v", err)
+			}
+			if err := binary.Read(bytes.NewReader(body), binary.LittleEndian, &format); err != nil {
+				return nil, fmt.Errorf("reading format: %v", err)
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, errors.New("data chunk before format chunk")
+			}
+			body := make([]byte, chunk.Size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("reading samples: %v", err)
+			}
+			samples, err := decodeSamples(body, format.AudioFormat, format.BitsPerSample)
+			if err != nil {
+				return nil, err
+			}
+			return &Audio{
+				SampleRate: int(format.SampleRate),
+				Channels:   int(format.Channels),
+				Samples:    samples,
+			}, nil
+
+		default:
+			// Skip chunks such as LIST, padded to an even size
+			if _, err := io.CopyN(io.Discard, r, int64(chunk.Size+chunk.Size%2)); err != nil {
+				return nil, fmt.Errorf("skipping %q chunk: %v", chunk.ID[:], err)
+			}
+		}
+	}
+}
+
+// decodeSamples converts raw sample data to float32
+func decodeSamples(body []byte, audioFormat, bits uint16) ([]float32, error) {
+	switch {
+	case audioFormat == formatPCM && bits == 16:
+		samples := make([]float32, len(body)/2)
+		for i := range samples {
+			samples[i] = float32(int16(binary.LittleEndian.Uint16(body[2*i:]))) / 32768
+		}
+		return samples, nil
+	case audioFormat == formatFloat && bits == 32:
+		samples := make([]float32, len(body)/4)
+		for i := range samples {
+			samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(body[4*i:]))
+		}
+		return samples, nil
+	default:
+		return nil, fmt.Errorf("unsupported WAV format %d with %d bits per sample (expected 16-bit PCM or 32-bit float)", audioFormat, bits)
+	}
+}
+
+// Write encodes audio as a 16-bit PCM WAV file, clipping samples outside
+// -1 to 1
+func Write(path string, audio *Audio) error {
+	var buf bytes.Buffer
+	if err := Encode(&buf, audio); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// Encode writes audio as a 16-bit PCM WAV stream
+func Encode(w io.Writer, audio *Audio) error {
+	channels := max(1, audio.Channels)
+	dataSize := uint32(2 * len(audio.Samples))
+
+	header := struct {
+		RIFF          [4]byte
+		Size          uint32
+		WAVE          [4]byte
+		FmtID         [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		DataID        [4]byte
+		DataSize      uint32
+	}{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		Size:          36 + dataSize,
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		FmtID:         [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		AudioFormat:   formatPCM,
+		Channels:      uint16(channels),
+		SampleRate:    uint32(audio.SampleRate),
+		ByteRate:      uint32(audio.SampleRate * channels * 2),
+		BlockAlign:    uint16(channels * 2),
+		BitsPerSample: 16,
+		DataID:        [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      dataSize,
+	}
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+
+	body := make([]byte, dataSize)
+	for i, sample := range audio.Samples {
+		scaled := math.Round(float64(sample) * 32768)
+		binary.LittleEndian.PutUint16(body[2*i:], uint16(int16(max(-32768, min(32767, scaled)))))
+	}
+	_, err := w.Write(body)
+	return err
+}
@@ -0,0 +1,90 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	audio := &Audio{SampleRate: 48000, Channels: 2}
+	for i := 0; i < 960; i++ {
+		audio.Samples = append(audio.Samples, float32(0.5*math.Sin(float64(i)/10)), -0.25)
+	}
+	audio.Samples = append(audio.Samples, 1.5, -1.5) // Clipped on write
+
+	path := filepath.Join(t.TempDir(), "round.wav")
+	if err := Write(path, audio); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	if got.SampleRate != 48000 || got.Channels != 2 || len(got.Samples) != len(audio.Samples) {
+		t.Fatalf("got %d Hz, %d channels, %d samples; want 48000 Hz, 2 channels, %d samples",
+			got.SampleRate, got.Channels, len(got.Samples), len(audio.Samples))
+	}
+	for i, sample := range audio.Samples {
+		want := max(-1, min(32767.0/32768, sample))
+		if math.Abs(float64(got.Samples[i]-want)) > 1.0/32768 {
+			t.Fatalf("sample %d = %v, want %v", i, got.Samples[i], want)
+		}
+	}
+}
+
+func TestDecodeFloatWithExtraChunks(t *testing.T) {
+	samples := []float32{0.1, -0.2, 0.3}
+
+	var buf bytes.Buffer
+	write := func(v any) { _ = binary.Write(&buf, binary.LittleEndian, v) }
+	buf.WriteString("RIFF")
+	write(uint32(0)) // Size isn't checked
+	buf.WriteString("WAVE")
+	buf.WriteString("LIST")
+	write(uint32(3))
+	buf.Write([]byte{1, 2, 3, 0}) // Odd-sized chunk plus padding
+	buf.WriteString("fmt ")
+	write(uint32(16))
+	write([]uint16{formatFloat, 1})
+	write([]uint32{16000, 64000})
+	write([]uint16{4, 32})
+	buf.WriteString("data")
+	write(uint32(4 * len(samples)))
+	write(samples)
+
+	audio, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if audio.SampleRate != 16000 || audio.Channels != 1 {
+		t.Errorf("got %d Hz, %d channels; want 16000 Hz mono", audio.SampleRate, audio.Channels)
+	}
+	for i, sample := range samples {
+		if audio.Samples[i] != sample {
+			t.Errorf("sample %d = %v, want %v", i, audio.Samples[i], sample)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", nil},
+		{"NotRIFF", []byte("RIFX\x00\x00\x00\x00WAVE")},
+		{"NoData", []byte("RIFF\x00\x00\x00\x00WAVE")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(bytes.NewReader(tt.data)); err == nil {
+				t.Error("Decode() succeeded, want error")
+			}
+		})
+	}
+}